@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a single line of the audit log describing a change of a route.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Action is what was requested, e.g. add or remove
+	Action string `json:"action"`
+	// Peer is the address of the client, empty for changes made by the server itself
+	Peer string `json:"peer,omitempty"`
+	// Identity is who the client claims or proves to be, if known
+	Identity string `json:"identity,omitempty"`
+	RouteId  string `json:"routeId"`
+	// Before and After are the route in Caddy JSON, null when it did not exist
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+	// Error is set when the change was refused
+	Error string `json:"error,omitempty"`
+	// Pushed reports whether Caddy accepted the conf with the change,
+	// PushError explains why not
+	Pushed    bool   `json:"pushed"`
+	PushError string `json:"pushError,omitempty"`
+}
+
+// Log appends entries as JSON lines to a file, which is rotated once it
+// would grow over maxSize: path is renamed to path.1, path.1 to path.2 and
+// so on, keeping at most keep rotated files.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	f       *os.File
+	size    int64
+}
+
+// Open opens the log at path for appending, creating it if missing.
+func Open(path string, maxSize int64, keep int) (*Log, error) {
+	l := &Log{path: path, maxSize: maxSize, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = st.Size()
+	return nil
+}
+
+// Write appends e, setting Entry.Time unless set.
+func (l *Log) Write(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.maxSize > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+// rotate shifts rotated files by one, dropping the oldest, and starts a new file.
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	if l.keep > 0 {
+		for i := l.keep - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%v.%d", l.path, i), fmt.Sprintf("%v.%d", l.path, i+1))
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Close closes the file of the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	f, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer f.Close()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		assert.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestRotate(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	entry := func(i int) Entry {
+		return Entry{
+			Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Action:  "add",
+			RouteId: fmt.Sprintf("r%d", i),
+			Before:  json.RawMessage("null"),
+			After:   json.RawMessage(fmt.Sprintf(`{"@id":"r%d"}`, i)),
+			Pushed:  true,
+		}
+	}
+	line, _ := json.Marshal(entry(0))
+	size := int64(len(line) + 1)
+
+	// Room for two entries per file
+	l, err := Open(path, size*2+size/2, 2)
+	a.NoError(err)
+	for i := 0; i < 7; i++ {
+		a.NoError(l.Write(entry(i)))
+	}
+	a.NoError(l.Close())
+
+	ids := func(p string) []string {
+		var ids []string
+		for _, e := range readEntries(t, p) {
+			a.False(e.Time.IsZero())
+			ids = append(ids, e.RouteId)
+		}
+		return ids
+	}
+	a.Equal([]string{"r6"}, ids(path))
+	a.Equal([]string{"r4", "r5"}, ids(path+".1"))
+	a.Equal([]string{"r2", "r3"}, ids(path+".2"))
+	a.NoFileExists(path + ".3")
+
+	// Reopening appends to the current file
+	l, err = Open(path, 1<<20, 2)
+	a.NoError(err)
+	a.NoError(l.Write(Entry{Action: "remove", RouteId: "r6", Before: json.RawMessage(`{"@id":"r6"}`), After: json.RawMessage("null")}))
+	a.NoError(l.Close())
+	a.Equal([]string{"r6", "r6"}, ids(path))
+}
@@ -0,0 +1,94 @@
+package caddy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AdminOptions configure how Caddy admin API is reached, see SetAdmin.
+type AdminOptions struct {
+	// CertFile and KeyFile are the client certificate presented to Caddy
+	// for mutual TLS
+	CertFile string
+	KeyFile  string
+	// CAFile verifies Caddy's certificate instead of the system roots
+	CAFile string
+	// Origin is sent as Origin header, for Caddy enforcing allowed origins
+	Origin string
+	// Timeout limits each request, zero means no limit
+	Timeout time.Duration
+}
+
+// SetAdmin sets the address of Caddy admin API, which is one of
+//
+//   - host:port or tcp/host:port
+//   - unix/path for a unix socket, e.g. unix//run/caddy/admin.sock
+//   - http:// or https:// URL
+func (c *Client) SetAdmin(addr string, opts AdminOptions) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	base := ""
+	switch {
+	case strings.HasPrefix(addr, "unix/"):
+		path := strings.TrimPrefix(addr, "unix/")
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		base = "http://localhost"
+	case strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://"):
+		u, err := url.Parse(addr)
+		if err != nil {
+			return fmt.Errorf("caddy admin address: %v", err)
+		}
+		base = strings.TrimSuffix(u.String(), "/")
+	default:
+		hostPort := strings.TrimPrefix(addr, "tcp/")
+		if _, _, err := net.SplitHostPort(hostPort); err != nil {
+			return fmt.Errorf("caddy admin address: %v", err)
+		}
+		base = "http://" + hostPort
+	}
+
+	tlsConf, err := adminTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+	transport.TLSClientConfig = tlsConf
+
+	c.adminURL = base
+	c.adminClient = &http.Client{Transport: transport, Timeout: opts.Timeout}
+	c.adminOrigin = opts.Origin
+	return nil
+}
+
+// adminTLSConfig loads the client certificate and CA of opts, if any.
+func adminTLSConfig(opts AdminOptions) (*tls.Config, error) {
+	conf := &tls.Config{}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("caddy admin client certificate: %v", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if opts.CAFile != "" {
+		b, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("caddy admin CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("caddy admin CA: no certificate found in %v", opts.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
@@ -2,63 +2,221 @@ package caddy
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"github.com/king8fisher/caddycfginjector/db"
+	"github.com/king8fisher/caddycfginjector/events"
 	"io"
 	"log/slog"
+	"math/rand"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
-// PatchCaddyCh receives string representation of conf for caddy and
-// will automatically send it to Caddy, provided PatchCaddy runs in the
-// background.
-var PatchCaddyCh = make(chan string, 1)
+// ErrUnavailable is returned when Caddy admin API cannot be reached.
+var ErrUnavailable = errors.New("caddy unavailable")
 
-// PatchCaddy will run in background and wait for
-// new conf sent to PatchCaddyCh.
-func PatchCaddy(ctx context.Context, port int) {
-	prev := ""
+// ErrVersionNotFound is returned when a version is not kept in history.
+var ErrVersionNotFound = errors.New("version not found")
+
+// ErrConflict is returned when Caddy's conf was changed by another writer
+// since it was read.
+var ErrConflict = errors.New("caddy conf changed concurrently")
+
+// Client keeps Caddy's conf in sync with the conf of a db.Store through
+// Caddy admin API. Create it with NewClient.
+type Client struct {
+	store *db.Store
+
+	// adminURL is the base URL of Caddy admin API requests are sent to with
+	// adminClient, see SetAdmin
+	adminURL    string
+	adminClient *http.Client
+	adminOrigin string
+
+	etagMutex sync.Mutex
+	// etag is the ETag of Caddy's conf the desired conf is based on, empty
+	// until known. Writes are sent with If-Match, so that Caddy refuses them
+	// with 412 once another writer changed its conf in the meantime.
+	etag string
+
+	historyMutex sync.Mutex
+	// history holds the last historySize versions, oldest first
+	history     []Version
+	historySize int
+	lastVersion uint64
+
+	// patchCh signals Patch that a conf was pushed
+	patchCh   chan struct{}
+	pushMutex sync.Mutex
+	pushed    uint64
+	// latest is the conf pushed last and not taken by Patch yet, if any.
+	// Guarded by pushMutex.
+	latest *patch
+
+	attemptMutex sync.Mutex
+	// attempted is the sequence number of the last conf Patch sent to
+	// Caddy and attemptErr its outcome. attemptedCh is closed after every
+	// attempt. Guarded by attemptMutex along with state.
+	attempted   uint64
+	attemptErr  error
+	attemptedCh chan struct{}
+	state       State
+
+	// applied is the last conf Caddy accepted, or empty if unknown, and
+	// lastGood holds its routes. Only accessed by Patch.
+	applied  string
+	lastGood []db.Route
+}
+
+// NewClient returns a client sending the conf of store to Caddy admin API on
+// localhost:2019 until SetAdmin is called.
+func NewClient(store *db.Store) *Client {
+	return &Client{
+		store:       store,
+		adminURL:    "http://localhost:2019",
+		adminClient: &http.Client{},
+		historySize: 20,
+		patchCh:     make(chan struct{}, 1),
+		attemptedCh: make(chan struct{}),
+	}
+}
+
+// Patch will run in background and wait for
+// new conf sent with Push.
+//
+// When incremental is set, only routes changed since the last conf Caddy
+// accepted are sent, see applyIncremental.
+//
+// Confs pushed within debounce after the first one of a burst are merged, so
+// that only the latest of them is sent. A conf equal to the one Caddy last
+// accepted is not sent again.
+//
+// A conf that failed to be sent is retried with backoff until Caddy accepts
+// it or a different conf is pushed, see CurrentState.
+func (c *Client) Patch(ctx context.Context, incremental bool, debounce time.Duration) {
+	var prev [sha256.Size]byte
+	var retry *patch
+	var retryC <-chan time.Time
+	failures := 0
 	for {
+		var p patch
 		select {
 		case <-ctx.Done():
 			return
-		case c := <-PatchCaddyCh:
-			_, err := postCaddyConfig(port, c)
-			if err != nil {
-				slog.Error("patch caddy config", "err", err)
-			} else {
-				if prev != c {
-					// Skip notifying for the same conf
-					slog.Info("patch caddy success", "conf", c)
-					prev = c
+		case <-retryC:
+			p = *retry
+		case <-c.patchCh:
+			if debounce > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(debounce):
 				}
 			}
+			// The signal of pushes merged in the window is stale
+			select {
+			case <-c.patchCh:
+			default:
+			}
+			var ok bool
+			if p, ok = c.takeLatest(); !ok {
+				continue
+			}
+			if retry != nil && p.conf == retry.conf {
+				// Pushing the conf waiting for retry again, e.g. on heartbeats,
+				// does not skip its backoff
+				retry.seq = p.seq
+				retry.reload = retry.reload || p.reload
+				c.postponeAttempt(p.seq)
+				continue
+			}
+		}
+		retry, retryC = nil, nil
+
+		if sha256.Sum256([]byte(p.conf)) == prev && !p.reload {
+			c.setAttempted(p.seq, nil, time.Time{})
+			continue
+		}
+		conf, err := c.applyConf(p.conf, incremental && !p.reload)
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			slog.Warn("caddy rejected conf, isolating rejected routes", "err", err)
+			err = c.isolateRejected(conf)
+		}
+		if err == nil {
+			failures = 0
+			prev = sha256.Sum256([]byte(conf))
+			if c.applied != "" {
+				c.recordVersion(c.applied)
+			}
+			c.setAttempted(p.seq, nil, time.Time{})
+			slog.Info("patch caddy success", "conf", conf)
+			c.store.Events().Publish(events.Event{Kind: events.Pushed})
+			continue
+		}
+
+		prev = [sha256.Size]byte{}
+		var next time.Time
+		if !errors.As(err, &rejected) {
+			// Caddy refusing the conf is not going to change by itself
+			failures++
+			d := backoff(failures)
+			next = time.Now().Add(d)
+			retry, retryC = &p, time.After(d)
 		}
+		c.setAttempted(p.seq, err, next)
+		slog.Error("patch caddy config", "err", err, "failures", failures, "retry", next)
+		c.store.Events().Publish(events.Event{Kind: events.PushFailed, Message: err.Error()})
+	}
+}
+
+const minBackoff = 500 * time.Millisecond
+const maxBackoff = 30 * time.Second
+
+// backoff returns the delay before retrying after the given number of failures
+// in a row. It doubles with each failure up to maxBackoff, with up to half of
+// it randomized so that injectors do not retry in lockstep.
+func backoff(failures int) time.Duration {
+	d := maxBackoff
+	if failures < 16 {
+		d = min(minBackoff<<(failures-1), maxBackoff)
 	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
 }
 
-// PollCaddy performs polling Caddy Server for its conf and pushes an initial conf
+// Poll performs polling Caddy Server for its conf and pushes an initial conf
 // in case it returns empty conf and init is set to true.
-func PollCaddy(ctx context.Context, port int, init bool) {
+//
+// Once the conf is received, Caddy keeps being reconciled every interval, see
+// reconcile. Zero interval stops polling instead.
+func (c *Client) Poll(ctx context.Context, init bool, interval time.Duration) {
 	t := time.NewTimer(time.Millisecond)
 	errCnt := 0
+	received := false
+	var rec reconciler
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-t.C:
-			conf, err := readConfig(port)
-			stop := false
+			if received {
+				rec.reconcile(c)
+				t.Reset(interval)
+				continue
+			}
+			conf, tag, err := c.readConfig()
 			if err != nil {
 				slog.Error("caddy response", "err", err)
 			} else {
-				if conf == "null" || conf == "null\n" {
+				if isNull(conf) {
 					if init {
 						slog.Info("attempting to inject initial config")
-						_, err := postCaddyConfig(port, db.InitialCaddyConfigSrc())
+						_, err := c.postCaddyConfig(c.store.InitialConfSrc())
 						if err != nil {
 							slog.Error("caddy initial conf response", "err", err)
 						}
@@ -66,53 +224,110 @@ func PollCaddy(ctx context.Context, port int, init bool) {
 					errCnt++
 					if errCnt%10 == 1 {
 						// Slowing down same error emission
-						slog.Error("caddy initial conf empty, skipping incoming routes")
+						slog.Error("caddy initial conf empty, holding incoming routes")
 					}
 				} else {
-					err := db.SetCaddyConf([]byte(conf))
+					merged, err := c.store.SetConf([]byte(conf))
 					if err != nil {
 						slog.Error("caddy initial conf rejected", "conf", conf, "err", err)
 					} else {
 						slog.Info("caddy initial conf received", "conf", conf)
-						stop = true
+						c.setETag(tag)
+						received = true
+						if len(merged) > 0 {
+							slog.Info("pending routes merged", "ids", merged)
+							if desired, err := c.store.ReadConf(); err == nil {
+								c.Push(desired)
+							}
+						}
 					}
 				}
 			}
-			if stop {
+			switch {
+			case received && interval <= 0:
 				t.Stop()
-			} else {
+			case received:
+				t.Reset(interval)
+			default:
 				t.Reset(time.Second * 2)
 			}
 		}
 	}
 }
 
-func readConfig(port int) (string, error) {
-	loadConfig, err := http.Get(fmt.Sprintf("http://localhost:%d/config", port))
+// readConfig returns Caddy's conf along with its ETag, if Caddy sends one.
+func (c *Client) readConfig() (string, string, error) {
+	body, header, err := c.adminRequest(http.MethodGet, "/config/", "")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer loadConfig.Body.Close()
-	b, err := io.ReadAll(loadConfig.Body)
-	if err != nil {
-		return "", err
+	return body, header.Get("Etag"), nil
+}
+
+// postCaddyConfig replaces Caddy's conf with cfg. Once the ETag of Caddy's
+// conf is known, cfg is sent to /config/ honoring If-Match rather than to /load.
+func (c *Client) postCaddyConfig(cfg string) (string, error) {
+	path := "/load"
+	if c.currentETag() != "" {
+		path = "/config/"
+	}
+	res, err := c.caddyRequest(http.MethodPost, path, cfg)
+	if err == nil {
+		c.syncETag(cfg)
 	}
-	return string(b), nil
+	return res, err
 }
 
-func postCaddyConfig(port int, cfg string) (string, error) {
-	loadConfig, err := http.Post(fmt.Sprintf("http://localhost:%d/load", port), "application/json",
-		strings.NewReader(cfg))
+// caddyRequest sends a request with JSON body, unless empty, to Caddy admin API
+// and returns the response body.
+func (c *Client) caddyRequest(method string, path string, body string) (string, error) {
+	res, _, err := c.adminRequest(method, path, body)
+	return res, err
+}
+
+// adminRequest is caddyRequest returning response headers as well. Writes
+// carry If-Match with the known ETag and fail with ErrConflict on 412.
+func (c *Client) adminRequest(method string, path string, body string) (string, http.Header, error) {
+	return c.adminRequestIfMatch(method, path, body, c.currentETag())
+}
+
+// adminRequestIfMatch is adminRequest sending writes with If-Match tag, or
+// without If-Match if tag is empty.
+func (c *Client) adminRequestIfMatch(method string, path string, body string, tag string) (string, http.Header, error) {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.adminURL+path, r)
+	if err != nil {
+		return "", nil, err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.adminOrigin != "" {
+		req.Header.Set("Origin", c.adminOrigin)
+	}
+	if tag != "" && method != http.MethodGet {
+		req.Header.Set("If-Match", tag)
+	}
+	resp, err := c.adminClient.Do(req)
 	if err != nil {
-		return "", err
+		return "", nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
-	defer loadConfig.Body.Close()
-	b, err := io.ReadAll(loadConfig.Body)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return "", nil, fmt.Errorf("%w: %v", ErrConflict, string(b))
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return "", nil, &RejectedError{StatusCode: resp.StatusCode, Body: string(b)}
 	}
-	if loadConfig.StatusCode != 200 {
-		return "", fmt.Errorf("caddy status code %d, body: %v, cfg: %v", loadConfig.StatusCode, string(b), cfg)
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("caddy status code %d, body: %v", resp.StatusCode, string(b))
 	}
-	return string(b), nil
+	return string(b), resp.Header, nil
 }
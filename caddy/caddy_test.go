@@ -0,0 +1,579 @@
+package caddy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/db"
+	"github.com/king8fisher/caddycfginjector/internal/routetest"
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCaddy serves Caddy's /config/ and /load endpoints and rejects any conf containing
+// a route with "bad" id. Routes can be changed through /id/ and routes
+// arrays of servers as well. The first fail requests are answered with 503,
+// as are all writes while failWrites is set.
+type fakeCaddy struct {
+	mu         sync.Mutex
+	requests   int
+	loads      int
+	ops        int
+	fail       int
+	failWrites bool
+	conf       string
+}
+
+func (f *fakeCaddy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, _ := io.ReadAll(r.Body)
+	f.requests++
+	if f.fail > 0 {
+		f.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	isConfig := strings.TrimSuffix(r.URL.Path, "/") == "/config"
+	if isConfig && r.Method == http.MethodGet {
+		w.Header().Set("Etag", f.etag())
+		if f.conf == "" {
+			_, _ = w.Write([]byte("null\n"))
+		} else {
+			_, _ = w.Write([]byte(f.conf))
+		}
+		return
+	}
+	if f.failWrites {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if tag := r.Header.Get("If-Match"); tag != "" && tag != f.etag() {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if r.URL.Path != "/load" && !isConfig {
+		f.serveRoute(w, r, b)
+		return
+	}
+	f.loads++
+	var c db.CaddyConf
+	_ = json.Unmarshal(b, &c)
+	if c.Apps.Http.Servers["myserver"].Routes != nil {
+		for _, route := range *c.Apps.Http.Servers["myserver"].Routes {
+			if route.Id == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"bad route"}`))
+				return
+			}
+		}
+	}
+	f.conf = string(b)
+}
+
+func (f *fakeCaddy) etag() string {
+	return fmt.Sprintf(`"/config/ %x"`, sha256.Sum256([]byte(f.conf)))
+}
+
+// set changes the conf as another writer would.
+func (f *fakeCaddy) set(conf string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conf = conf
+}
+
+func (f *fakeCaddy) serveRoute(w http.ResponseWriter, r *http.Request, b []byte) {
+	routes, _ := db.ConfRoutes(f.conf)
+	var route db.Route
+	_ = json.Unmarshal(b, &route)
+	found := false
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/id/"):
+		id := strings.TrimPrefix(r.URL.Path, "/id/")
+		for i := range routes {
+			if routes[i].Id != id {
+				continue
+			}
+			found = true
+			if r.Method == http.MethodDelete {
+				routes = append(routes[:i], routes[i+1:]...)
+			} else {
+				route.Server = routes[i].Server
+				routes[i] = route
+			}
+			break
+		}
+	case strings.HasSuffix(r.URL.Path, "/routes") && r.Method == http.MethodPost:
+		found = true
+		route.Server = strings.Split(r.URL.Path, "/")[5]
+		routes = append(routes, route)
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.ops++
+	f.conf, _ = db.ConfWithRoutes(f.conf, routes)
+}
+
+// startFakeCaddy starts fakeCaddy and returns it along with a client of a
+// new store pointed to it.
+func startFakeCaddy(t *testing.T) (*fakeCaddy, *Client) {
+	f := &fakeCaddy{}
+	s := httptest.NewServer(f)
+	t.Cleanup(s.Close)
+	c := NewClient(db.NewStore())
+	if err := c.SetAdmin(s.Listener.Addr().String(), AdminOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return f, c
+}
+
+func TestIsolateRejected(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	_, err := c.store.SetConf([]byte(c.store.InitialConfSrc()))
+	a.Nil(err)
+	_, err = c.store.AddRoutes([]*pb.Route{routetest.Route("good"), routetest.Route("bad"), routetest.Route("other")}, db.Registration{})
+	a.Nil(err)
+	conf, err := c.store.ReadConf()
+	a.Nil(err)
+	_, err = c.postCaddyConfig(conf)
+	var rejected *RejectedError
+	a.True(errors.As(err, &rejected), "conf with a bad route should be rejected")
+	a.Nil(c.isolateRejected(conf))
+
+	var applied db.CaddyConf
+	a.Nil(json.Unmarshal([]byte(f.conf), &applied))
+	var ids []string
+	for _, r := range *applied.Apps.Http.Servers["myserver"].Routes {
+		ids = append(ids, r.Id)
+	}
+	a.Equal([]string{"good", "other"}, ids, "caddy should end up with every accepted route")
+
+	_, ok := c.store.GetRoute("bad")
+	a.False(ok, "rejected route should be removed")
+	_, err = c.store.AddRoute(routetest.Route("bad"), db.Registration{})
+	var re *db.RouteRejectedError
+	a.True(errors.As(err, &re), "unchanged rejected route should be refused")
+	a.Equal("bad route", re.Reason)
+}
+
+func TestPushWait(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, false, 0)
+
+	seq := c.Push(c.store.InitialConfSrc())
+	a.Nil(c.Wait(ctx, seq), "caddy should accept the conf")
+	a.Equal(1, f.loads)
+
+	short, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	a.ErrorIs(c.Wait(short, seq+1), context.DeadlineExceeded, "nothing was pushed after seq")
+
+	seq = c.Push(`{"apps":{"http":{"servers":{"myserver":{"routes":[{"@id":"bad"}]}}}}}`)
+	a.Nil(c.Wait(ctx, seq), "conf without the rejected route should be applied")
+	a.NotNil(c.store.RouteRejection("bad"), "rejected route should be reported by db")
+}
+
+func confWith(routes ...string) string {
+	conf := `{"apps":{"http":{"servers":{"myserver":{"listen":[":443"],"routes":[`
+	for i, r := range routes {
+		if i > 0 {
+			conf += ","
+		}
+		conf += r
+	}
+	return conf + `]}}}}}`
+}
+
+func TestRouteOps(t *testing.T) {
+	a := assert.New(t)
+	from := confWith(`{"@id":"a"}`, `{"@id":"b"}`, `{"handle":[{"handler":"static_response"}]}`)
+
+	ops, ok, err := routeOps(from, confWith(`{"@id":"a","terminal":true}`, `{"handle":[{"handler":"static_response"}]}`, `{"@id":"c"}`))
+	a.Nil(err)
+	a.True(ok)
+	var got []string
+	for _, op := range ops {
+		got = append(got, op.method+" "+op.path)
+	}
+	a.Equal([]string{"PATCH /id/a", "DELETE /id/b", "POST /config/apps/http/servers/myserver/routes"}, got)
+
+	ops, ok, err = routeOps(from, from)
+	a.Nil(err)
+	a.True(ok)
+	a.Empty(ops, "same conf needs no change")
+
+	_, ok, err = routeOps(from, confWith(`{"@id":"c"}`, `{"@id":"a"}`, `{"@id":"b"}`, `{"handle":[{"handler":"static_response"}]}`))
+	a.Nil(err)
+	a.False(ok, "inserting before existing routes cannot be done by appending")
+
+	_, ok, err = routeOps(from, `{"apps":{"http":{"servers":{"myserver":{"listen":[":8443"],"routes":[]}}}}}`)
+	a.Nil(err)
+	a.False(ok, "changes beyond routes need loading")
+}
+
+func TestApplyConfIncremental(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+
+	_, err := c.applyConf(confWith(`{"@id":"a"}`), true)
+	a.Nil(err)
+	a.Equal(1, f.loads, "unknown state of caddy needs loading")
+	_, err = c.applyConf(confWith(`{"@id":"a","terminal":true}`, `{"@id":"b"}`), true)
+	a.Nil(err)
+	a.Equal(1, f.loads)
+	a.Equal(2, f.ops)
+	routes, _ := db.ConfRoutes(f.conf)
+	a.Equal(2, len(routes))
+	a.Equal(json.RawMessage("true"), routes[0].Extra["terminal"])
+
+	_, err = c.applyConf(confWith(`{"@id":"b"}`), false)
+	a.Nil(err)
+	a.Equal(2, f.loads, "incremental is off")
+	c.applied = confWith(`{"@id":"x"}`) // Caddy's state diverged
+	_, err = c.applyConf(confWith(`{"@id":"b"}`, `{"@id":"c"}`), true)
+	a.Nil(err)
+	a.Equal(3, f.loads, "failed incremental update falls back to loading")
+}
+
+func TestIncrementalETag(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	_, err := c.applyConf(confWith(`{"@id":"a"}`), true)
+	a.Nil(err)
+	a.Equal(f.etag(), c.currentETag())
+
+	sent := f.requests
+	_, err = c.applyConf(confWith(`{"@id":"a"}`, `{"@id":"b"}`, `{"@id":"c"}`, `{"@id":"d"}`), true)
+	a.Nil(err)
+	a.Equal(1, f.loads)
+	a.Equal(sent+4, f.requests, "etag should be read once after the last of 3 ops")
+	a.Equal(f.etag(), c.currentETag())
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, true, 0)
+
+	base := confWith(`{"@id":"static"}`)
+	_, err := c.store.SetConf([]byte(base))
+	a.Nil(err)
+	_, err = c.store.AddRoute(routetest.Route("app"), db.Registration{})
+	a.Nil(err)
+	conf, _ := c.store.ReadConf()
+	a.Nil(c.Wait(ctx, c.Push(conf)))
+	a.Equal(1, f.loads)
+
+	var rec reconciler
+	rec.reconcile(c)
+	seq, _ := c.settled()
+	a.Nil(c.Wait(ctx, seq))
+	a.Equal(1, f.loads, "nothing to do while caddy has the desired conf")
+
+	f.mu.Lock()
+	f.conf = `{"apps":{"http":{"servers":{"myserver":{"listen":[":8443"],"routes":[{"@id":"static"}]}}}}}`
+	f.mu.Unlock()
+	rec.reconcile(c)
+	seq, _ = c.settled()
+	a.Nil(c.Wait(ctx, seq))
+	a.Equal(2, f.loads, "restored routes should be loaded rather than patched")
+	routes, _ := db.ConfRoutes(f.conf)
+	a.Equal(2, len(routes))
+	a.Contains(f.conf, `":8443"`, "changed base conf should be kept")
+
+	f.mu.Lock()
+	f.conf = ""
+	f.mu.Unlock()
+	rec.reconcile(c)
+	seq, _ = c.settled()
+	a.Nil(c.Wait(ctx, seq))
+	a.Equal(3, f.loads, "conf should be restored when caddy lost it")
+	conf, _ = c.store.ReadConf()
+	a.True(sameConf(f.conf, conf))
+}
+
+func TestReconcileFailing(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, true, 0)
+
+	_, err := c.store.SetConf([]byte(confWith(`{"@id":"static"}`)))
+	a.Nil(err)
+	_, err = c.store.AddRoute(routetest.Route("app"), db.Registration{})
+	a.Nil(err)
+	conf, _ := c.store.ReadConf()
+	a.Nil(c.Wait(ctx, c.Push(conf)))
+
+	f.mu.Lock()
+	f.failWrites = true
+	f.mu.Unlock()
+	_, err = c.store.RemoveRoute("app")
+	a.Nil(err)
+	conf, _ = c.store.ReadConf()
+	a.NotNil(c.Wait(ctx, c.Push(conf)), "caddy should refuse the write")
+
+	var rec reconciler
+	rec.reconcile(c)
+	_, ok := c.store.GetRoute("app")
+	a.False(ok, "stale conf of caddy should not be adopted while retrying")
+
+	f.mu.Lock()
+	f.failWrites = false
+	f.mu.Unlock()
+	a.Eventually(func() bool { return c.CurrentState().InSync() }, 4*time.Second, 10*time.Millisecond)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a.NotContains(f.conf, `"app"`)
+}
+
+func TestPushDebounce(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, false, 50*time.Millisecond)
+
+	var seq uint64
+	for i := 0; i < 10; i++ {
+		seq = c.Push(confWith(`{"@id":"r` + strconv.Itoa(i) + `"}`))
+	}
+	a.Nil(c.Wait(ctx, seq))
+	a.Equal(1, f.loads, "burst should be merged into one push")
+	routes, _ := db.ConfRoutes(f.conf)
+	a.Equal("r9", routes[0].Id, "latest conf should win")
+
+	a.Nil(c.Wait(ctx, c.Push(confWith(`{"@id":"r9"}`))))
+	a.Equal(1, f.loads, "unchanged conf should not be sent again")
+	a.Nil(c.Wait(ctx, c.push(confWith(`{"@id":"r9"}`), true)))
+	a.Equal(2, f.loads, "reload should be sent regardless")
+}
+
+func TestPushRetry(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	f.fail = 2
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, false, 0)
+
+	seq := c.Push(confWith(`{"@id":"retried"}`))
+	a.NotNil(c.Wait(ctx, seq), "first attempt should fail")
+	st := c.CurrentState()
+	a.False(st.InSync())
+	a.Equal(1, st.Failures)
+	a.False(st.NextRetry.IsZero())
+
+	a.Eventually(func() bool { return c.CurrentState().InSync() }, 4*time.Second, 10*time.Millisecond)
+	st = c.CurrentState()
+	a.Equal(seq, st.Applied)
+	a.Zero(st.Failures)
+	a.True(st.NextRetry.IsZero())
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a.Contains(f.conf, "retried")
+}
+
+func TestPushRetryRepeated(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	f.fail = 1000
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, false, 0)
+	requests := func() int {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.requests
+	}
+
+	// Heartbeats push the same conf while Caddy is down
+	conf := confWith(`{"@id":"app"}`)
+	for i := 0; i < 100; i++ {
+		seq := c.Push(conf)
+		a.NotNil(c.Wait(ctx, seq), "failure should be reported without waiting for the retry")
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.LessOrEqual(requests(), 4, "same conf should be retried with backoff")
+	a.LessOrEqual(c.CurrentState().Failures, 4)
+
+	sent := requests()
+	a.NotNil(c.Wait(ctx, c.Push(confWith(`{"@id":"app"}`, `{"@id":"other"}`))))
+	a.Equal(sent+1, requests(), "changed conf should be sent at once")
+}
+
+func TestBackoff(t *testing.T) {
+	a := assert.New(t)
+	a.GreaterOrEqual(backoff(1), minBackoff/2)
+	a.LessOrEqual(backoff(1), minBackoff)
+	a.GreaterOrEqual(backoff(3), 2*minBackoff)
+	a.LessOrEqual(backoff(100), maxBackoff)
+	a.GreaterOrEqual(backoff(100), maxBackoff/2)
+}
+
+func TestAdminUnixSocket(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	l, err := net.Listen("unix", path)
+	a.Nil(err)
+	s := httptest.NewUnstartedServer(&fakeCaddy{conf: confWith()})
+	s.Listener = l
+	s.Start()
+	defer s.Close()
+
+	c := NewClient(db.NewStore())
+	a.Nil(c.SetAdmin("unix/"+path, AdminOptions{}))
+	conf, _, err := c.readConfig()
+	a.Nil(err)
+	a.True(sameConf(confWith(), conf))
+}
+
+func TestAdminTLS(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	var origin string
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin = r.Header.Get("Origin")
+		_, _ = w.Write([]byte("null"))
+	}))
+	defer s.Close()
+
+	c := NewClient(db.NewStore())
+	a.Nil(c.SetAdmin(s.URL, AdminOptions{}))
+	_, _, err := c.readConfig()
+	a.ErrorIs(err, ErrUnavailable, "caddy certificate should not be trusted")
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	a.Nil(os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0o600))
+	a.Nil(c.SetAdmin(s.URL, AdminOptions{CAFile: ca, Origin: "https://injector", Timeout: time.Second}))
+	conf, _, err := c.readConfig()
+	a.Nil(err)
+	a.True(isNull(conf))
+	a.Equal("https://injector", origin)
+
+	a.NotNil(c.SetAdmin("localhost", AdminOptions{}), "port should be required")
+	a.NotNil(c.SetAdmin("localhost:2019", AdminOptions{CertFile: "missing.pem", KeyFile: "missing.key"}))
+}
+
+func TestConcurrentWriter(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	a.Nil(c.store.SetInitialConf(confWith(`{"@id":"static"}`)))
+	f.set(c.store.InitialConfSrc())
+	conf, tag, err := c.readConfig()
+	a.Nil(err)
+	_, err = c.store.SetConf([]byte(conf))
+	a.Nil(err)
+	c.setETag(tag)
+
+	_, err = c.store.AddRoute(routetest.Route("app"), db.Registration{})
+	a.Nil(err)
+	desired, _ := c.store.ReadConf()
+	_, err = c.applyConf(desired, true)
+	a.Nil(err)
+	a.Equal(1, f.loads)
+	a.NotEqual(tag, c.currentETag(), "etag should follow the write")
+
+	f.set(confWith(`{"@id":"static"}`, `{"@id":"app"}`, `{"@id":"operator"}`))
+	_, err = c.store.AddRoute(routetest.Route("app2"), db.Registration{})
+	a.Nil(err)
+	desired, _ = c.store.ReadConf()
+	applied, err := c.applyConf(desired, true)
+	a.Nil(err)
+	a.NotEqual(desired, applied, "conf should be merged into the changed one")
+	routes, _ := db.ConfRoutes(f.conf)
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.Id)
+	}
+	a.Equal([]string{"static", "operator", "app", "app2"}, ids, "change of the other writer should be kept")
+	a.True(sameConf(f.conf, applied))
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	f, c := startFakeCaddy(t)
+	c.SetHistorySize(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Patch(ctx, false, 0)
+
+	a.Nil(c.store.SetInitialConf(confWith(`{"@id":"static"}`)))
+	_, err := c.store.SetConf([]byte(c.store.InitialConfSrc()))
+	a.Nil(err)
+	for _, id := range []string{"one", "two", "three"} {
+		_, err = c.store.AddRoute(routetest.Route(id), db.Registration{})
+		a.Nil(err)
+		conf, _ := c.store.ReadConf()
+		a.Nil(c.Wait(ctx, c.Push(conf)))
+	}
+	versions := c.Versions()
+	a.Equal(2, len(versions), "history should be bounded")
+	first := versions[1]
+	routes, _ := db.ConfRoutes(first.Conf)
+	a.Equal(3, len(routes))
+	a.Equal([]string{"one", "two"}, first.Owned)
+	_, ok := c.GetVersion(first.Number - 1)
+	a.False(ok, "oldest version should be dropped")
+
+	latest, _ := c.GetVersion(0)
+	a.Equal(versions[0], latest)
+	diff, err := Diff(first, latest)
+	a.Nil(err)
+	a.Contains(diff, "+++ version "+strconv.FormatUint(latest.Number, 10))
+	a.Contains(diff, `+              "@id": "three",`)
+
+	_, err = c.store.RemoveRoute("two")
+	a.Nil(err)
+	seq, err := c.Rollback(first.Number, db.Registration{TTL: time.Minute})
+	a.Nil(err)
+	a.Nil(c.Wait(ctx, seq))
+	_, ok = c.store.GetRoute("three")
+	a.False(ok, "routes added since should be removed")
+	info, _ := c.store.GetRoute("two")
+	a.False(info.Expires.IsZero(), "restored route should get the lease")
+	info, _ = c.store.GetRoute("static")
+	a.True(info.Registered.IsZero())
+	a.True(sameConf(first.Conf, f.conf))
+	latest, _ = c.GetVersion(0)
+	a.True(sameConf(first.Conf, latest.Conf), "rollback should be a new version")
+
+	_, err = c.Rollback(1000, db.Registration{})
+	a.ErrorIs(err, ErrVersionNotFound)
+}
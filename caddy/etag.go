@@ -0,0 +1,56 @@
+package caddy
+
+import (
+	"log/slog"
+)
+
+// maxConflicts limits how many times in a row a conf is merged into the conf
+// changed by another writer before giving up until the next retry.
+const maxConflicts = 3
+
+func (c *Client) currentETag() string {
+	c.etagMutex.Lock()
+	defer c.etagMutex.Unlock()
+	return c.etag
+}
+
+func (c *Client) setETag(tag string) {
+	c.etagMutex.Lock()
+	defer c.etagMutex.Unlock()
+	c.etag = tag
+}
+
+// syncETag reads the ETag of Caddy's conf after a write of expected. It is
+// taken only if Caddy still holds expected, unless expected is empty, so that
+// a change of another writer racing with the write fails the next write.
+func (c *Client) syncETag(expected string) {
+	live, tag, err := c.readConfig()
+	if err != nil || tag == "" {
+		return
+	}
+	if expected == "" || sameConf(live, expected) {
+		c.setETag(tag)
+	}
+}
+
+// remerge reads Caddy's conf changed by another writer, adopts it as the new
+// base conf with managed routes merged into it and returns the new desired conf.
+func (c *Client) remerge() (string, error) {
+	live, tag, err := c.readConfig()
+	if err != nil {
+		return "", err
+	}
+	if !isNull(live) {
+		if err := c.store.ReconcileConf([]byte(live)); err != nil {
+			return "", err
+		}
+		c.setLastGood(live)
+	}
+	c.setETag(tag)
+	conf, err := c.store.ReadConf()
+	if err != nil {
+		return "", err
+	}
+	slog.Info("routes merged into conf changed by another writer", "conf", conf)
+	return conf, nil
+}
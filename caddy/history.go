@@ -0,0 +1,119 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/db"
+	"github.com/pmezard/go-difflib/difflib"
+	"time"
+)
+
+// Version is a conf Caddy accepted.
+type Version struct {
+	// Number increases with every version
+	Number uint64
+	Pushed time.Time
+	Conf   string
+	// Owned holds ids of routes managed by the injector when it was recorded
+	Owned []string
+}
+
+// SetHistorySize sets how many versions are kept. Expected to be called
+// before Patch is started.
+func (c *Client) SetHistorySize(n int) {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	c.historySize = n
+}
+
+// recordVersion adds conf to history unless it is the latest version already.
+func (c *Client) recordVersion(conf string) {
+	owned := c.store.OwnedIds()
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	if c.historySize <= 0 {
+		return
+	}
+	if len(c.history) > 0 && sameConf(c.history[len(c.history)-1].Conf, conf) {
+		return
+	}
+	c.lastVersion++
+	c.history = append(c.history, Version{Number: c.lastVersion, Pushed: time.Now(), Conf: conf, Owned: owned})
+	if len(c.history) > c.historySize {
+		c.history = append([]Version(nil), c.history[len(c.history)-c.historySize:]...)
+	}
+}
+
+// Versions returns kept versions, most recent first.
+func (c *Client) Versions() []Version {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	versions := make([]Version, 0, len(c.history))
+	for i := len(c.history) - 1; i >= 0; i-- {
+		versions = append(versions, c.history[i])
+	}
+	return versions
+}
+
+// GetVersion returns the version with number, or the latest one for 0, and
+// whether it is kept.
+func (c *Client) GetVersion(number uint64) (Version, bool) {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	if number == 0 && len(c.history) > 0 {
+		return c.history[len(c.history)-1], true
+	}
+	for _, v := range c.history {
+		if v.Number == number {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// Diff returns a unified diff of indented confs of versions from and to.
+func Diff(from, to Version) (string, error) {
+	a, err := indent(from.Conf)
+	if err != nil {
+		return "", err
+	}
+	b, err := indent(to.Conf)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: fmt.Sprintf("version %d", from.Number),
+		ToFile:   fmt.Sprintf("version %d", to.Number),
+		Context:  3,
+	})
+}
+
+func indent(conf string) (string, error) {
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(conf), "", "  "); err != nil {
+		return "", err
+	}
+	b.WriteByte('\n')
+	return b.String(), nil
+}
+
+// Rollback replaces the conf of db with the version with number and pushes
+// it to Caddy, see db.Store.RestoreConf, which registers routes owned again
+// with reg. The returned sequence number can be passed to Wait.
+func (c *Client) Rollback(number uint64, reg db.Registration) (uint64, error) {
+	v, ok := c.GetVersion(number)
+	if !ok {
+		return 0, fmt.Errorf("version %d: %w", number, ErrVersionNotFound)
+	}
+	if err := c.store.RestoreConf([]byte(v.Conf), v.Owned, reg); err != nil {
+		return 0, err
+	}
+	conf, err := c.store.ReadConf()
+	if err != nil {
+		return 0, err
+	}
+	return c.push(conf, true), nil
+}
@@ -0,0 +1,189 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/db"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// routeOp is a single change of a route through Caddy admin API.
+type routeOp struct {
+	method string
+	path   string
+	route  *db.Route
+}
+
+// applyConf sends conf to Caddy, incrementally when possible and by loading
+// it entirely otherwise, and returns the conf Caddy ended up with. It differs
+// from conf when another writer changed Caddy's conf, see remerge.
+func (c *Client) applyConf(conf string, incremental bool) (string, error) {
+	for conflicts := 0; ; conflicts++ {
+		err := c.applyConfOnce(conf, incremental)
+		if !errors.Is(err, ErrConflict) || conflicts == maxConflicts {
+			return conf, err
+		}
+		slog.Warn("caddy conf changed by another writer", "err", err)
+		if conf, err = c.remerge(); err != nil {
+			return conf, err
+		}
+	}
+}
+
+func (c *Client) applyConfOnce(conf string, incremental bool) error {
+	if incremental && c.applied != "" {
+		ok, err := c.applyIncremental(conf)
+		if err != nil {
+			slog.Warn("incremental caddy update failed, loading whole conf", "err", err)
+		} else if ok {
+			c.setLastGood(conf)
+			return nil
+		}
+	}
+	_, err := c.postCaddyConfig(conf)
+	if err == nil {
+		c.setLastGood(conf)
+	}
+	return err
+}
+
+// applyIncremental changes routes that differ between applied and conf
+// through Caddy's /id/<id> endpoints and the routes arrays of servers.
+// It reports false when conf cannot be reached this way, for example when
+// parts of conf other than routes changed.
+func (c *Client) applyIncremental(conf string) (bool, error) {
+	ops, ok, err := routeOps(c.applied, conf)
+	if err != nil || !ok {
+		return false, err
+	}
+	if len(ops) == 0 {
+		return true, nil
+	}
+	// Every op changes the ETag of Caddy's conf, so only the first one is
+	// checked against it. A writer getting in between is noticed by the
+	// next write, since the ETag is only taken if Caddy ends up with conf.
+	tag := c.currentETag()
+	for _, op := range ops {
+		body := ""
+		if op.route != nil {
+			b, err := json.Marshal(op.route)
+			if err != nil {
+				return false, err
+			}
+			body = string(b)
+		}
+		if _, _, err := c.adminRequestIfMatch(op.method, op.path, body, tag); err != nil {
+			// Caddy's state diverged from applied
+			return false, fmt.Errorf("%v %v: %w", op.method, op.path, err)
+		}
+		tag = ""
+	}
+	c.syncETag(conf)
+	return true, nil
+}
+
+// routeOps returns requests turning routes of from into routes of to. It
+// reports false if to cannot be reached by these requests alone.
+func routeOps(from string, to string) ([]routeOp, bool, error) {
+	fromBase, err := db.ConfWithRoutes(from, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	toBase, err := db.ConfWithRoutes(to, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if fromBase != toBase {
+		return nil, false, nil
+	}
+	fromRoutes, err := db.ConfRoutes(from)
+	if err != nil {
+		return nil, false, err
+	}
+	toRoutes, err := db.ConfRoutes(to)
+	if err != nil {
+		return nil, false, err
+	}
+
+	wanted := map[string]db.Route{}
+	for _, r := range toRoutes {
+		if r.Id == "" {
+			// Routes without an id can only be changed by loading
+			continue
+		}
+		wanted[r.Id] = r
+	}
+
+	var ops []routeOp
+	// result mimics routes of Caddy after ops are applied
+	var result []db.Route
+	for _, r := range fromRoutes {
+		w, ok := wanted[r.Id]
+		switch {
+		case r.Id == "":
+			result = append(result, r)
+		case !ok || w.Server != r.Server:
+			ops = append(ops, routeOp{method: http.MethodDelete, path: idPath(r.Id)})
+		case !reflect.DeepEqual(w, r):
+			ops = append(ops, routeOp{method: http.MethodPatch, path: idPath(r.Id), route: &w})
+			result = append(result, w)
+		default:
+			result = append(result, r)
+		}
+	}
+	existing := map[string]bool{}
+	for _, r := range result {
+		existing[r.Id] = true
+	}
+	for _, r := range toRoutes {
+		if r.Id == "" || existing[r.Id] {
+			continue
+		}
+		r := r
+		ops = append(ops, routeOp{method: http.MethodPost, path: routesPath(r.Server), route: &r})
+		result = appendToServer(result, r)
+	}
+
+	if !sameRoutes(result, toRoutes) {
+		// Positions of routes differ from what appending produces
+		return nil, false, nil
+	}
+	return ops, true, nil
+}
+
+func sameRoutes(a []db.Route, b []db.Route) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !reflect.DeepEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendToServer inserts r after the last route of its server, keeping
+// routes ordered by server as db.ConfRoutes does.
+func appendToServer(routes []db.Route, r db.Route) []db.Route {
+	i := len(routes)
+	for i > 0 && routes[i-1].Server > r.Server {
+		i--
+	}
+	routes = append(routes, db.Route{})
+	copy(routes[i+1:], routes[i:])
+	routes[i] = r
+	return routes
+}
+
+func idPath(id string) string {
+	return "/id/" + url.PathEscape(id)
+}
+
+func routesPath(server string) string {
+	return "/config/apps/http/servers/" + url.PathEscape(server) + "/routes"
+}
@@ -0,0 +1,104 @@
+package caddy
+
+import (
+	"context"
+	"time"
+)
+
+// patch is a conf queued for Patch along with its sequence number.
+// reload makes Patch load the whole conf even in incremental mode.
+type patch struct {
+	conf   string
+	seq    uint64
+	reload bool
+}
+
+// Push queues string representation of conf to be sent to Caddy, provided
+// Patch runs in the background. A conf pushed before it was sent is
+// replaced, so only the latest one reaches Caddy. The returned sequence
+// number can be passed to Wait.
+func (c *Client) Push(conf string) uint64 {
+	return c.push(conf, false)
+}
+
+func (c *Client) push(conf string, reload bool) uint64 {
+	c.pushMutex.Lock()
+	defer c.pushMutex.Unlock()
+	c.pushed++
+	if c.latest != nil {
+		reload = reload || c.latest.reload
+	}
+	c.latest = &patch{conf: conf, seq: c.pushed, reload: reload}
+	select {
+	case c.patchCh <- struct{}{}:
+	default:
+		// Patch is already signaled
+	}
+	return c.pushed
+}
+
+// takeLatest returns the conf pushed last and whether there was one.
+func (c *Client) takeLatest() (patch, bool) {
+	c.pushMutex.Lock()
+	defer c.pushMutex.Unlock()
+	if c.latest == nil {
+		return patch{}, false
+	}
+	p := *c.latest
+	c.latest = nil
+	return p, true
+}
+
+// settled returns the sequence number of the last pushed conf and whether
+// Patch has already sent it to Caddy.
+func (c *Client) settled() (uint64, bool) {
+	c.pushMutex.Lock()
+	seq := c.pushed
+	c.pushMutex.Unlock()
+	c.attemptMutex.Lock()
+	defer c.attemptMutex.Unlock()
+	return seq, c.attempted >= seq
+}
+
+// setAttempted records the outcome of sending the conf with seq to Caddy and
+// when it is retried, zero if not.
+func (c *Client) setAttempted(seq uint64, err error, retry time.Time) {
+	c.attemptMutex.Lock()
+	defer c.attemptMutex.Unlock()
+	c.recordAttemptNonBlocking(seq, err, retry)
+	c.attempted = seq
+	c.attemptErr = err
+	close(c.attemptedCh)
+	c.attemptedCh = make(chan struct{})
+}
+
+// postponeAttempt releases waiters of seq, whose conf equals the one waiting
+// to be retried, with the outcome of the last attempt. The retry sends it.
+func (c *Client) postponeAttempt(seq uint64) {
+	c.attemptMutex.Lock()
+	defer c.attemptMutex.Unlock()
+	c.attempted = seq
+	close(c.attemptedCh)
+	c.attemptedCh = make(chan struct{})
+}
+
+// Wait blocks until the conf pushed with seq, or a later one, was sent to
+// Caddy and returns the outcome, unless ctx is done first.
+func (c *Client) Wait(ctx context.Context, seq uint64) error {
+	for {
+		c.attemptMutex.Lock()
+		if c.attempted >= seq {
+			err := c.attemptErr
+			c.attemptMutex.Unlock()
+			return err
+		}
+		ch := c.attemptedCh
+		c.attemptMutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package caddy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/db"
+	"log/slog"
+	"reflect"
+)
+
+// RejectedError is returned when Caddy refuses to load a conf.
+type RejectedError struct {
+	StatusCode int
+	// Body is the response of Caddy explaining the rejection
+	Body string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("caddy rejected conf with status code %d, body: %v", e.StatusCode, e.Body)
+}
+
+// reason extracts the error message from Caddy's response body.
+func (e *RejectedError) reason() string {
+	var b struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(e.Body), &b); err == nil && b.Error != "" {
+		return b.Error
+	}
+	return e.Body
+}
+
+func (c *Client) setLastGood(conf string) {
+	if routes, err := db.ConfRoutes(conf); err == nil {
+		c.applied = conf
+		c.lastGood = routes
+	} else {
+		c.applied = ""
+		c.lastGood = nil
+	}
+}
+
+// isolateRejected finds routes of the rejected conf that Caddy refuses to load.
+//
+// Caddy is rolled back to the routes unchanged since lastGood, then every
+// changed route is loaded on top of them one by one. Routes Caddy rejects are
+// quarantined with db.Store.QuarantineRoute, others are kept, so that Caddy ends up
+// with every route it accepts.
+func (c *Client) isolateRejected(conf string) error {
+	routes, err := db.ConfRoutes(conf)
+	if err != nil {
+		return err
+	}
+	good := map[string]db.Route{}
+	for _, r := range c.lastGood {
+		good[r.Id] = r
+	}
+
+	keep := make([]bool, len(routes))
+	for i, r := range routes {
+		g, ok := good[r.Id]
+		// Routes without an id are not managed by the injector and are trusted
+		keep[i] = r.Id == "" || ok && reflect.DeepEqual(g, r)
+	}
+	build := func() (string, error) {
+		var kept []db.Route
+		for i, r := range routes {
+			if keep[i] {
+				kept = append(kept, r)
+			}
+		}
+		return db.ConfWithRoutes(conf, kept)
+	}
+
+	base, err := build()
+	if err != nil {
+		return err
+	}
+	if _, err := c.postCaddyConfig(base); err != nil {
+		// Caddy refuses even the routes it accepted before: not a route problem
+		return err
+	}
+	c.setLastGood(base)
+
+	for i, r := range routes {
+		if keep[i] {
+			continue
+		}
+		keep[i] = true
+		candidate, err := build()
+		if err != nil {
+			return err
+		}
+		_, err = c.postCaddyConfig(candidate)
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			keep[i] = false
+			slog.Warn("caddy rejected route, quarantining it", "id", r.Id, "err", rejected.reason())
+			c.store.QuarantineRoute(r, rejected.reason())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.setLastGood(candidate)
+	}
+	return nil
+}
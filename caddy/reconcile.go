@@ -0,0 +1,86 @@
+package caddy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// reconciler remembers the confs behind its last push, so that a difference
+// Caddy keeps reporting after loading a conf is not pushed over and over.
+type reconciler struct {
+	live    string
+	desired string
+}
+
+// reconcile compares the conf loaded by Caddy with the desired one and pushes
+// the latter when they differ, e.g. after Caddy restarted with its on-disk
+// conf. Changes Caddy got from elsewhere are adopted as the new base conf with
+// db.Store.ReconcileConf before that, so that only managed routes are restored.
+//
+// Nothing is done while a pushed conf is on its way to Caddy or failed to
+// reach it, as Caddy then holds a stale conf which is not to be adopted.
+func (rec *reconciler) reconcile(c *Client) {
+	seq, ok := c.settled()
+	if !ok || c.failing() {
+		return
+	}
+	live, tag, err := c.readConfig()
+	if err != nil {
+		slog.Error("caddy response", "err", err)
+		return
+	}
+	if s, ok := c.settled(); !ok || s != seq || c.failing() {
+		return
+	}
+	desired, err := c.store.ReadConf()
+	if err != nil {
+		return
+	}
+	if sameConf(live, desired) {
+		c.setETag(tag)
+		return
+	}
+
+	if !isNull(live) {
+		if err := c.store.ReconcileConf([]byte(live)); err != nil {
+			slog.Error("caddy conf not reconciled", "conf", live, "err", err)
+			return
+		}
+		c.setETag(tag)
+		desired, err = c.store.ReadConf()
+		if err != nil || sameConf(live, desired) {
+			slog.Info("caddy base conf changed, adopted", "conf", live)
+			return
+		}
+	}
+	if live == rec.live && desired == rec.desired {
+		return
+	}
+	slog.Warn("caddy conf is missing managed routes, pushing again", "conf", live)
+	rec.live = live
+	rec.desired = desired
+	c.push(desired, true)
+}
+
+// failing reports whether the last attempt to send a conf to Caddy failed or
+// is to be retried.
+func (c *Client) failing() bool {
+	st := c.CurrentState()
+	return st.LastError != nil || !st.NextRetry.IsZero()
+}
+
+func isNull(conf string) bool {
+	return strings.TrimSpace(conf) == "null"
+}
+
+// sameConf reports whether both confs hold the same JSON regardless of
+// formatting and order of keys.
+func sameConf(a, b string) bool {
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,55 @@
+package caddy
+
+import (
+	"time"
+)
+
+// State describes whether the desired conf, the one pushed last, has been
+// applied by Caddy.
+type State struct {
+	// Desired is the sequence number of the conf pushed last
+	Desired uint64
+	// Applied is the sequence number of the last conf Caddy accepted
+	Applied uint64
+	// LastError is the outcome of the last attempt, nil on success
+	LastError   error
+	LastAttempt time.Time
+	LastSuccess time.Time
+	// Failures counts attempts failed in a row
+	Failures int
+	// NextRetry is zero unless a failed conf waits to be sent again
+	NextRetry time.Time
+}
+
+// InSync reports whether Caddy has applied the desired conf.
+func (s State) InSync() bool {
+	return s.Applied >= s.Desired && s.LastError == nil
+}
+
+// recordAttemptNonBlocking updates state with the outcome of sending the conf
+// with seq. next is when it is sent again after a failure, if ever.
+func (c *Client) recordAttemptNonBlocking(seq uint64, err error, next time.Time) {
+	now := time.Now()
+	c.state.LastAttempt = now
+	c.state.LastError = err
+	c.state.NextRetry = next
+	if err != nil {
+		c.state.Failures++
+		return
+	}
+	c.state.Failures = 0
+	c.state.Applied = seq
+	c.state.LastSuccess = now
+}
+
+// CurrentState returns the state of sending pushed confs to Caddy.
+func (c *Client) CurrentState() State {
+	c.pushMutex.Lock()
+	desired := c.pushed
+	c.pushMutex.Unlock()
+	c.attemptMutex.Lock()
+	defer c.attemptMutex.Unlock()
+	s := c.state
+	s.Desired = desired
+	return s
+}
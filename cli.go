@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const commandsUsage = `Commands sent to a running server at --host and --port:
+  versions                list confs Caddy accepted, most recent first
+  diff <from> [<to>]      show the difference between versions, <to> defaults to the latest
+  rollback <version>      push the version to Caddy again
+`
+
+// runCommand runs a command of commandsUsage against the server at target
+// and returns the exit code.
+func runCommand(target string, args []string) int {
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	c := pb.NewCaddyCfgInjectorClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch {
+	case args[0] == "versions" && len(args) == 1:
+		err = listVersions(ctx, c)
+	case args[0] == "diff" && (len(args) == 2 || len(args) == 3):
+		err = diffVersions(ctx, c, args[1:])
+	case args[0] == "rollback" && len(args) == 2:
+		err = rollback(ctx, c, args[1])
+	default:
+		fmt.Fprint(os.Stderr, commandsUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func listVersions(ctx context.Context, c pb.CaddyCfgInjectorClient) error {
+	reply, err := c.ListVersions(ctx, &pb.ListVersionsRequest{})
+	if err != nil {
+		return err
+	}
+	for _, v := range reply.Versions {
+		fmt.Printf("%d\t%v\t%v\n", v.Number, v.Pushed.AsTime().Local().Format(time.RFC3339), strings.Join(v.RouteIds, ","))
+	}
+	return nil
+}
+
+func diffVersions(ctx context.Context, c pb.CaddyCfgInjectorClient, args []string) error {
+	var numbers []uint64
+	for _, a := range args {
+		n, err := strconv.ParseUint(a, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", a)
+		}
+		numbers = append(numbers, n)
+	}
+	in := &pb.DiffVersionsRequest{From: numbers[0]}
+	if len(numbers) > 1 {
+		in.To = numbers[1]
+	}
+	reply, err := c.DiffVersions(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Print(reply.Diff)
+	return nil
+}
+
+func rollback(ctx context.Context, c pb.CaddyCfgInjectorClient, arg string) error {
+	n, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", arg)
+	}
+	reply, err := c.Rollback(ctx, &pb.RollbackRequest{Version: n})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rolled back to version %d as version %d\n", n, reply.Version)
+	return nil
+}
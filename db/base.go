@@ -0,0 +1,109 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/valyala/fasttemplate"
+	"io"
+	"os"
+	"strings"
+)
+
+const defaultInitialConf = `
+{
+  "apps": {
+    "http": {
+      "servers": {
+        "myserver": {
+          "automatic_https": {
+            "skip": []
+          },
+          "listen": [":443"],
+          "routes": []
+        }
+      }
+    }
+  }
+}
+`
+
+// SetInitialConf replaces the conf pushed to Caddy when it has none.
+// The conf has to contain the default server with a listener.
+func (st *Store) SetInitialConf(src string) error {
+	var c CaddyConf
+	if err := json.Unmarshal([]byte(src), &c); err != nil {
+		return fmt.Errorf("invalid base conf: %v", err)
+	}
+	st.mu.Lock()
+	server := st.defaultServer
+	st.mu.Unlock()
+	if s := c.Apps.Http.Servers[server]; s == nil || s.Listen == nil {
+		return fmt.Errorf("invalid base conf: server %q with listen not found", server)
+	}
+	st.initialConfMutex.Lock()
+	defer st.initialConfMutex.Unlock()
+	st.initialConf = src
+	return nil
+}
+
+// LoadBaseConf reads the conf template at path and expands its ${VAR}
+// placeholders with values read from valuesPath, unless empty, and from the
+// environment otherwise. A placeholder without a value is an error.
+func LoadBaseConf(path string, valuesPath string) (string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	values := map[string]string{}
+	if valuesPath != "" {
+		f, err := os.Open(valuesPath)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if values, err = readValues(f); err != nil {
+			return "", fmt.Errorf("%v: %w", valuesPath, err)
+		}
+	}
+	conf, err := expandTemplate(string(src), func(name string) (string, bool) {
+		if v, ok := values[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	})
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", path, err)
+	}
+	return conf, nil
+}
+
+// expandTemplate replaces ${VAR} placeholders of src with values returned by lookup.
+func expandTemplate(src string, lookup func(name string) (string, bool)) (string, error) {
+	return fasttemplate.ExecuteFuncStringWithErr(src, "${", "}", func(w io.Writer, tag string) (int, error) {
+		v, ok := lookup(strings.TrimSpace(tag))
+		if !ok {
+			return 0, fmt.Errorf("no value for ${%v}", tag)
+		}
+		return w.Write([]byte(v))
+	})
+}
+
+// readValues reads NAME=value lines, skipping empty lines and # comments.
+func readValues(r io.Reader) (map[string]string, error) {
+	values := map[string]string{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := string(bytes.TrimSpace(s.Bytes()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected NAME=value", n)
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values, s.Err()
+}
@@ -3,128 +3,209 @@ package db
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/king8fisher/caddycfginjector/events"
 	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
-	"log/slog"
-	"os"
+	"net"
+	"reflect"
 	"slices"
+	"strconv"
 	"sync"
 )
 
-var caddyConf = &CaddyConf{}
-var caddyConfMutex sync.Mutex
+// Store holds the conf of Caddy along with the routes added by clients,
+// which are merged into it. Create it with NewStore.
+type Store struct {
+	mu   sync.Mutex
+	conf *CaddyConf
+	// doc is the whole conf as received from Caddy, including everything
+	// CaddyConf does not know about. ReadConf only replaces the routes of
+	// the servers in it.
+	doc map[string]any
+	// meta holds routeMeta of each route added with AddRoute
+	meta map[string]*routeMeta
+	// pending holds routes waiting for the conf in the order they were added
+	pending []pendingRoute
+	// rejected holds the last rejected version of each route by its id
+	rejected map[string]rejectedRoute
+	// dropped holds why routes were left out of the conf without Caddy
+	// rejecting them, by their id, until they are added or removed again
+	dropped   map[string]error
+	ownership Ownership
+	// defaultServer is the server routes are added to unless they name one
+	defaultServer string
+	// stateDir is where owned routes are saved, empty unless SetStateDir was called
+	stateDir string
+	// saveMutex serializes saveState, savedKey identifies the routes saved last
+	saveMutex sync.Mutex
+	savedKey  string
 
-// ReadCaddyConf sends string representation of
+	initialConfMutex sync.Mutex
+	initialConf      string
+
+	events *events.Bus
+}
+
+// NewStore returns a store without a conf, which holds added routes pending
+// until the conf is received from Caddy with SetConf.
+func NewStore() *Store {
+	return &Store{
+		conf:          &CaddyConf{},
+		meta:          map[string]*routeMeta{},
+		rejected:      map[string]rejectedRoute{},
+		dropped:       map[string]error{},
+		defaultServer: "myserver",
+		initialConf:   defaultInitialConf,
+		events:        events.NewBus(),
+	}
+}
+
+// Events returns the bus changes of routes are published to.
+func (st *Store) Events() *events.Bus {
+	return st.events
+}
+
+// ReadConf sends string representation of
 // a config unless empty.
-func ReadCaddyConf() (string, error) {
-	caddyConfMutex.Lock()
-	defer caddyConfMutex.Unlock()
-	if isCaddyConfEmptyNonBlocking() {
-		return "", fmt.Errorf("empty config")
+func (st *Store) ReadConf() (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.isCaddyConfEmptyNonBlocking() {
+		return "", ErrEmptyConf
 	}
-	b, err := json.Marshal(caddyConf)
+	var v any = st.conf
+	if st.doc != nil {
+		doc := st.doc
+		for _, name := range serverNames(st.conf) {
+			s := st.conf.Apps.Http.Servers[name]
+			if s == nil || s.Routes == nil {
+				continue
+			}
+			var err error
+			doc, err = withRoutes(doc, name, *s.Routes)
+			if err != nil {
+				return "", err
+			}
+		}
+		v = doc
+	}
+	b, err := json.Marshal(v)
 	if err != nil {
 		return "", err
 	}
 	return string(b), nil
 }
 
-func SetCaddyConf(conf []byte) error {
-	caddyConfMutex.Lock()
-	defer caddyConfMutex.Unlock()
+// SetConf sets the conf received from Caddy. Routes added before it was
+// set are merged into it and their ids returned, so that the conf can be sent
+// back to Caddy.
+//
+// Unless SetStateDir was called, routes of the conf with ids the initial conf
+// does not have are taken as left by a previous run and owned from then on.
+func (st *Store) SetConf(conf []byte) ([]string, error) {
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
 	var c CaddyConf
 	err := json.Unmarshal(conf, &c)
 	if err != nil {
-		return fmt.Errorf("unable to fit conf: %v", err)
+		return nil, fmt.Errorf("unable to fit conf: %v", err)
 	} else {
 		if isConfEmpty(c) {
-			return fmt.Errorf("unable to set internal conf: seems empty")
+			return nil, fmt.Errorf("unable to set internal conf: seems empty")
 		}
-		caddyConf = &c
-		return nil
+		if c.Apps.Http.Servers[st.defaultServer] == nil {
+			return nil, fmt.Errorf("unable to set internal conf: server %q not found", st.defaultServer)
+		}
+		assignServers(&c)
+		doc, err := parseDoc(conf)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fit conf: %v", err)
+		}
+		st.conf = &c
+		st.doc = doc
+		if st.stateDir == "" {
+			st.ownLeftoversNonBlocking()
+		}
+		merged := st.mergePendingNonBlocking()
+		st.arrangeNonBlocking()
+		return merged, nil
 	}
 }
 
-func resetConfToEmpty() {
-	caddyConfMutex.Lock()
-	defer caddyConfMutex.Unlock()
-	caddyConf = &CaddyConf{}
+// InitialConfSrc returns the conf pushed to Caddy when it has none,
+// see SetInitialConf.
+func (st *Store) InitialConfSrc() string {
+	st.initialConfMutex.Lock()
+	defer st.initialConfMutex.Unlock()
+	return st.initialConf
 }
 
-func resetConfToMinimumNonEmptyConf() {
-	caddyConfMutex.Lock()
-	defer caddyConfMutex.Unlock()
-	c := InitialCaddyConfig()
-	caddyConf = &c
-}
-
-func InitialCaddyConfigSrc() string {
-	return `
-{
-  "apps": {
-    "http": {
-      "servers": {
-        "myserver": {
-          "automatic_https": {
-            "skip": []
-          },
-          "listen": [":443"],
-          "routes": []
-        }
-      }
-    }
-  }
-}
-`
-}
-
-func InitialCaddyConfig() CaddyConf {
+func (st *Store) InitialConf() CaddyConf {
 	var c CaddyConf
-	v := InitialCaddyConfigSrc()
+	v := st.InitialConfSrc()
 	// Asserting the absence of this error with tests
 	_ = json.Unmarshal([]byte(v), &c)
 	return c
 }
 
+// isConfEmpty reports whether conf has no server with a listener.
 func isConfEmpty(conf CaddyConf) bool {
-	if conf.Apps.Http.Servers.Myserver.AutomaticHttps.Skip == nil {
-		return true
-	}
-	if conf.Apps.Http.Servers.Myserver.Listen == nil {
-		return true
+	for _, s := range conf.Apps.Http.Servers {
+		if s != nil && s.Listen != nil {
+			return false
+		}
 	}
-	return false
+	return true
 }
 
-func isCaddyConfEmptyNonBlocking() bool {
-	if caddyConf.Apps.Http.Servers.Myserver.AutomaticHttps.Skip == nil {
-		return true
-	}
-	if caddyConf.Apps.Http.Servers.Myserver.Listen == nil {
-		return true
-	}
-	return false
+func (st *Store) isCaddyConfEmptyNonBlocking() bool {
+	return isConfEmpty(*st.conf)
 }
 
 // patchRoute changes route element to the passed if its Route.Id matches existing Route.Id.
-func patchRoute(r Route) {
-	caddyConfMutex.Lock()
-	defer caddyConfMutex.Unlock()
+// Route.Server defaults to the default server.
+func (st *Store) patchRoute(r Route) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if r.Server == "" {
+		r.Server = st.defaultServer
+	}
+	_ = st.patchRouteNonBlocking(r)
+}
 
+// patchRouteNonBlocking adds r to its Route.Server, replacing the route with
+// the same id, which is moved if it belongs to a different server.
+func (st *Store) patchRouteNonBlocking(r Route) error {
 	// Guard empty configuration
-	if isCaddyConfEmptyNonBlocking() {
-		return
+	if st.isCaddyConfEmptyNonBlocking() {
+		return nil
+	}
+	if err := st.checkServerNonBlocking(r); err != nil {
+		return err
 	}
 
+	kind := events.RouteAdded
+	changed := true
+	if prev, ok := st.findRouteNonBlocking(r.Id); ok && prev.Server != r.Server {
+		// Moving the route to another server
+		st.removeFromServerNonBlocking(prev.Server, r.Id)
+		kind = events.RouteUpdated
+	}
+
+	server := st.conf.Apps.Http.Servers[r.Server]
 	var routes []Route
 
-	if caddyConf.Apps.Http.Servers.Myserver.Routes == nil {
+	if server.Routes == nil {
 		routes = append(routes, r)
 	} else {
 		added := false
-		for _, rr := range *caddyConf.Apps.Http.Servers.Myserver.Routes {
+		for _, rr := range *server.Routes {
 			if rr.Id == r.Id {
 				routes = append(routes, r)
 				added = true
+				kind = events.RouteUpdated
+				// Repeated announcement of the same route is not a change
+				changed = !reflect.DeepEqual(rr, r)
 			} else {
 				routes = append(routes, rr)
 			}
@@ -134,46 +215,268 @@ func patchRoute(r Route) {
 		}
 	}
 
-	caddyConf.Apps.Http.Servers.Myserver.Routes = &routes
+	server.Routes = &routes
+	if changed {
+		st.events.Publish(events.Event{Kind: kind, RouteId: r.Id, Route: routeToProto(r)})
+	}
+	return nil
+}
+
+// removeRoute deletes the route element with the matching Route.Id and reports whether it existed.
+func (st *Store) removeRoute(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.removeRouteNonBlocking(id)
+}
+
+func (st *Store) removeRouteNonBlocking(id string) bool {
+	delete(st.meta, id)
+	if st.dropPendingNonBlocking(id) {
+		return true
+	}
+
+	r, ok := st.findRouteNonBlocking(id)
+	if !ok {
+		return false
+	}
+	st.removeFromServerNonBlocking(r.Server, id)
+	st.events.Publish(events.Event{Kind: events.RouteRemoved, RouteId: id})
+	return true
+}
+
+// removeFromServerNonBlocking deletes the route with id from the server.
+func (st *Store) removeFromServerNonBlocking(server string, id string) {
+	s := st.conf.Apps.Http.Servers[server]
+	if s == nil || s.Routes == nil {
+		return
+	}
+	// Keep an empty array rather than null in the conf sent to Caddy
+	routes := []Route{}
+	for _, rr := range *s.Routes {
+		if rr.Id != id {
+			routes = append(routes, rr)
+		}
+	}
+	s.Routes = &routes
 }
 
-func transportProtocolToString(protocol pb.Transport_Protocol) string {
+// transportProtocolToString returns the Caddy transport protocol of protocol,
+// failing on values unknown to this server, e.g. sent by a newer client.
+func transportProtocolToString(protocol pb.Transport_Protocol) (string, error) {
 	switch protocol {
 	case pb.Transport_HTTP:
-		return "http"
+		return "http", nil
 	case pb.Transport_FastCGI:
-		return "fastcgi"
+		return "fastcgi", nil
 	default:
-		slog.Error("unknown transport protocol", "protocol", protocol.String())
-		os.Exit(1)
+		return "", fmt.Errorf("unknown transport protocol %d", protocol)
 	}
-	return ""
 }
 
-func AddRoute(r *pb.Route) error {
-	err := validateRoute(r)
+func transportProtocolFromString(protocol string) pb.Transport_Protocol {
+	switch protocol {
+	case "fastcgi":
+		return pb.Transport_FastCGI
+	default:
+		return pb.Transport_HTTP
+	}
+}
+
+// routeToProto converts r back to the shape it was received in by AddRoute.
+// Handlers other than reverse_proxy are skipped.
+func routeToProto(r Route) *pb.Route {
+	var handles []*pb.Handle
+	for _, h := range r.Handles {
+		if h.Handler != "reverse_proxy" {
+			continue
+		}
+		var upstreams []*pb.Upstream
+		for _, u := range h.Upstreams {
+			host, port, err := net.SplitHostPort(u.Dial)
+			if err != nil {
+				host = u.Dial
+			}
+			p, _ := strconv.ParseUint(port, 10, 32)
+			upstreams = append(upstreams, &pb.Upstream{
+				Dial: &pb.Dial{Host: host, Port: uint32(p)},
+			})
+		}
+		handles = append(handles, &pb.Handle{
+			Handler: &pb.Handle_ReverseProxy{
+				ReverseProxy: &pb.ReverseProxy{
+					Transport: &pb.Transport{
+						Protocol: transportProtocolFromString(h.Transport.Protocol),
+					},
+					Upstreams: upstreams,
+				},
+			},
+		})
+	}
+	var matches []*pb.Match
+	for _, m := range r.Matches {
+		matches = append(matches, &pb.Match{
+			Hosts: slices.Clone(m.Hosts),
+			Paths: slices.Clone(m.Paths),
+		})
+	}
+	return &pb.Route{
+		Id:      r.Id,
+		Handles: handles,
+		Matches: matches,
+		Server:  r.Server,
+	}
+}
+
+// RouteChange is a route as it was before and after AddRoute, AddRoutes or
+// RemoveRoute changed it. Before or After is nil where the route did not
+// exist. Routes pending until the conf is received exist as well.
+type RouteChange struct {
+	Id     string
+	Before *Route
+	After  *Route
+}
+
+// lookupRouteNonBlocking returns the route with id, pending or not, or nil.
+func (st *Store) lookupRouteNonBlocking(id string) *Route {
+	for _, p := range st.pending {
+		if p.route.Id == id {
+			r := p.route
+			return &r
+		}
+	}
+	if r, ok := st.findRouteNonBlocking(id); ok {
+		return &r
+	}
+	return nil
+}
+
+// AddRoute converts r and adds or replaces the route with the same Route.Id.
+// Until the conf is received from Caddy the route is kept pending, see SetConf.
+//
+// reg is recorded along with the route, and a positive Registration.TTL
+// (re)starts the lease of the route, see ExpireRoutes.
+func (st *Store) AddRoute(r *pb.Route, reg Registration) (RouteChange, error) {
+	if err := validateRoute(r); err != nil {
+		return RouteChange{}, err
+	}
+	a, err := st.routeFromProto(r)
 	if err != nil {
-		return err
+		return RouteChange{}, err
+	}
+
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := st.checkRejectedNonBlocking(a); err != nil {
+		return RouteChange{}, err
+	}
+	delete(st.dropped, a.Id)
+	change := RouteChange{Id: a.Id, Before: st.lookupRouteNonBlocking(a.Id)}
+	if st.isCaddyConfEmptyNonBlocking() {
+		st.queueRouteNonBlocking(a, reg)
+		change.After = st.lookupRouteNonBlocking(a.Id)
+		return change, nil
+	}
+	if err := st.checkOwnedNonBlocking(a.Id); err != nil {
+		return RouteChange{}, err
 	}
+	if err := st.patchRouteNonBlocking(a); err != nil {
+		return RouteChange{}, err
+	}
+	st.registerRouteNonBlocking(a.Id, reg)
+	st.arrangeNonBlocking()
+	change.After = st.lookupRouteNonBlocking(a.Id)
+	return change, nil
+}
+
+// AddRoutes adds or replaces all routes as one unit: either every route is
+// applied or, when any of them is invalid, none is.
+func (st *Store) AddRoutes(rs []*pb.Route, reg Registration) ([]RouteChange, error) {
+	ids := map[string]bool{}
+	for i, r := range rs {
+		if err := validateRoute(r); err != nil {
+			return nil, prefixFieldError(fmt.Sprintf("routes[%d]", i), err)
+		}
+		if ids[r.Id] {
+			return nil, fieldErrorf(fmt.Sprintf("routes[%d].id", i), "duplicate id %q", r.Id)
+		}
+		ids[r.Id] = true
+	}
+	var routes []Route
+	for i, r := range rs {
+		route, err := st.routeFromProto(r)
+		if err != nil {
+			return nil, prefixFieldError(fmt.Sprintf("routes[%d]", i), err)
+		}
+		routes = append(routes, route)
+	}
+
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, r := range routes {
+		if err := st.checkRejectedNonBlocking(r); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range routes {
+		delete(st.dropped, r.Id)
+	}
+	var changes []RouteChange
+	for _, r := range routes {
+		changes = append(changes, RouteChange{Id: r.Id, Before: st.lookupRouteNonBlocking(r.Id)})
+	}
+	if st.isCaddyConfEmptyNonBlocking() {
+		for _, r := range routes {
+			st.queueRouteNonBlocking(r, reg)
+		}
+	} else {
+		for i, r := range routes {
+			if err := st.checkServerNonBlocking(r); err != nil {
+				return nil, prefixFieldError(fmt.Sprintf("routes[%d]", i), err)
+			}
+			if err := st.checkOwnedNonBlocking(r.Id); err != nil {
+				return nil, prefixFieldError(fmt.Sprintf("routes[%d]", i), err)
+			}
+		}
+		for _, r := range routes {
+			_ = st.patchRouteNonBlocking(r)
+			st.registerRouteNonBlocking(r.Id, reg)
+		}
+		st.arrangeNonBlocking()
+	}
+	for i := range changes {
+		changes[i].After = st.lookupRouteNonBlocking(changes[i].Id)
+	}
+	return changes, nil
+}
+
+// routeFromProto converts r, which is expected to pass validateRoute, to the
+// route of the conf.
+func (st *Store) routeFromProto(r *pb.Route) (Route, error) {
 	var handles []Handle
-	for _, h := range r.Handles {
-		switch h := h.Handler.(type) {
+	for i, h := range r.Handles {
+		switch h := h.GetHandler().(type) {
 		case *pb.Handle_ReverseProxy:
 			var upstreams []Upstream
-			for _, u := range h.ReverseProxy.Upstreams {
+			for _, u := range h.ReverseProxy.GetUpstreams() {
 				upstreams = append(
 					upstreams,
-					Upstream{Dial: fmt.Sprintf("%v:%v", u.Dial.Host, u.Dial.Port)})
+					Upstream{Dial: net.JoinHostPort(u.GetDial().GetHost(), strconv.FormatUint(uint64(u.GetDial().GetPort()), 10))})
+			}
+			protocol, err := transportProtocolToString(h.ReverseProxy.GetTransport().GetProtocol())
+			if err != nil {
+				return Route{}, fieldErrorf(fmt.Sprintf("handles[%d].reverseProxy.transport.protocol", i), "%v", err)
 			}
 			handles = append(handles, Handle{
 				Handler: "reverse_proxy",
 				Transport: Transport{
-					Protocol: transportProtocolToString(h.ReverseProxy.Transport.Protocol),
+					Protocol: protocol,
 				},
 				Upstreams: upstreams,
 			})
 		default:
-			slog.Warn("unknown handler type", "handler", h)
+			return Route{}, fieldErrorf(fmt.Sprintf("handles[%d]", i), "unknown handler")
 		}
 	}
 	var matches []Match
@@ -183,21 +486,33 @@ func AddRoute(r *pb.Route) error {
 			Paths: slices.Clone(m.Paths),
 		})
 	}
-	a := Route{
+	server := r.Server
+	if server == "" {
+		server = st.defaultServer
+	}
+	return Route{
 		Id:      r.Id,
 		Handles: handles,
 		Matches: matches,
-	}
-	patchRoute(a)
-	return nil
+		Server:  server,
+	}, nil
 }
 
-func validateRoute(r *pb.Route) error {
-	if r.Id == "" {
-		return fmt.Errorf("id cannot be empty")
+// RemoveRoute removes a previously added route by its Route.Id. Whether such
+// a route existed is reported by RouteChange.Before. Routes of the base conf
+// are only removed when Ownership.AllowOverride is set.
+func (st *Store) RemoveRoute(id string) (RouteChange, error) {
+	if id == "" {
+		return RouteChange{}, fieldErrorf("id", "cannot be empty")
 	}
-	if len(r.Handles) == 0 {
-		return fmt.Errorf("handles should contain at least one element")
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := st.checkOwnedNonBlocking(id); err != nil {
+		return RouteChange{}, err
 	}
-	return nil
+	delete(st.dropped, id)
+	change := RouteChange{Id: id, Before: st.lookupRouteNonBlocking(id)}
+	st.removeRouteNonBlocking(id)
+	return change, nil
 }
@@ -1,107 +1,183 @@
 package db
 
 import (
+	"encoding/json"
+	"github.com/king8fisher/caddycfginjector/events"
+	"github.com/king8fisher/caddycfginjector/internal/routetest"
 	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 )
 
 func Test(t *testing.T) {
-	a := assert.New(t)
-	a.Equal(*caddyConf, CaddyConf{})
-	a.Equal(true, isCaddyConfEmptyNonBlocking(), "initially empty")
-
+	t.Run("testEmpty", testEmpty)
 	t.Run("testPatchRoute", testPatchRoute)
-	t.Run("testResetConf", testResetConf)
 	t.Run("testAddInvalidRoute", testAddInvalidRoute)
 	t.Run("testAddRouteRace", testAddRouteRace)
-	t.Run("testNotEmpty", testNotEmpty)
-	t.Run("testResetConf_again", testResetConf)
-	t.Run("testEmpty", testEmpty)
+	t.Run("testRemoveRoute", testRemoveRoute)
+	t.Run("testExpireRoutes", testExpireRoutes)
+	t.Run("testListRoutes", testListRoutes)
+	t.Run("testRouteEvents", testRouteEvents)
+	t.Run("testAddRoutes", testAddRoutes)
+	t.Run("testRouteChanges", testRouteChanges)
+	t.Run("testPendingRoutes", testPendingRoutes)
+	t.Run("testLosslessConf", testLosslessConf)
+	t.Run("testServers", testServers)
+	t.Run("testReconcileConf", testReconcileConf)
+	t.Run("testOwnership", testOwnership)
+	t.Run("testOwnershipRestart", testOwnershipRestart)
+	t.Run("testPersistState", testPersistState)
+	t.Run("testRestoreConf", testRestoreConf)
+	t.Run("testStoresIsolated", testStoresIsolated)
 }
 
-func TestInitialCaddyConfig(t *testing.T) {
-	a := assert.New(t)
-	c := InitialCaddyConfig()
-	a.NotEmpty(c.Apps.Http.Servers.Myserver.Listen)
-	a.Equal([]string{":443"}, c.Apps.Http.Servers.Myserver.Listen)
+// newMinimumStore returns a store holding the initial conf as if it was
+// received from Caddy.
+func newMinimumStore(t *testing.T) *Store {
+	st := NewStore()
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	assert.Nil(t, err)
+	return st
 }
 
-func testResetConf(t *testing.T) {
-	a := assert.New(t)
-	resetConfToEmpty()
-	a.Equal(*caddyConf, CaddyConf{}, "back to reset configuration")
+// errOf returns the error of a call returning a value as well, e.g. AddRoute.
+func errOf[T any](_ T, err error) error {
+	return err
 }
 
-func testNotEmpty(t *testing.T) {
+func TestInitialConf(t *testing.T) {
 	a := assert.New(t)
-	a.NotEqual(*caddyConf, CaddyConf{}, "configuration shouldn't be empty")
-	//r, _ := json.MarshalIndent(caddyConf, "", "  ")
-	//fmt.Println(string(r))
+	c := NewStore().InitialConf()
+	a.NotEmpty(c.Apps.Http.Servers["myserver"].Listen)
+	a.Equal([]string{":443"}, c.Apps.Http.Servers["myserver"].Listen)
 }
 
 func testEmpty(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	a.Equal(*st.conf, CaddyConf{}, "configuration should be empty")
+	a.Equal(true, st.isCaddyConfEmptyNonBlocking(), "initially empty")
+	_, err := st.ReadConf()
+	a.ErrorIs(err, ErrEmptyConf)
+	a.NotEqual(*newMinimumStore(t).conf, CaddyConf{}, "configuration shouldn't be empty")
+}
+
+func testStoresIsolated(t *testing.T) {
+	t.Parallel()
 	a := assert.New(t)
-	a.Equal(*caddyConf, CaddyConf{}, "configuration shouldn't be empty")
+	st1, st2 := newMinimumStore(t), newMinimumStore(t)
+	ch, unsubscribe := st2.Events().Subscribe(10)
+	defer unsubscribe()
+	st1.patchRoute(Route{Id: "0"})
+	_, ok := st1.GetRoute("0")
+	a.True(ok)
+	_, ok = st2.GetRoute("0")
+	a.False(ok, "route should only be added to its store")
+	a.Empty(ch, "events should only be published to subscribers of the store")
 }
 
 func testPatchRoute(t *testing.T) {
+	t.Parallel()
 	a := assert.New(t)
-	a.Equal(*caddyConf, CaddyConf{})
+	st := NewStore()
+	a.Equal(*st.conf, CaddyConf{})
 	route0 := Route{
 		Id:      "0",
 		Handles: nil,
 		Matches: nil,
 	}
-	patchRoute(route0)
-	a.Equal(*caddyConf, CaddyConf{}, "adding to empty conf produces empty conf")
-	resetConfToMinimumNonEmptyConf()
-	a.NotEqual(*caddyConf, CaddyConf{}, "resetConfToMinimumNonEmptyConf() shouldn't result in an empty config")
-	a.Equal(false, isCaddyConfEmptyNonBlocking())
-	patchRoute(route0)
-	a.NotEqual(caddyConf, CaddyConf{}, "adding to non-empty conf produces non-empty conf")
-	a.NotNil(caddyConf.Apps.Http.Servers.Myserver.Routes, "after adding a route can't be nil")
-	a.Equal("0", (*caddyConf.Apps.Http.Servers.Myserver.Routes)[0].Id)
-	patchRoute(route0) // Re-adding the same route
-	a.Equal("0", (*caddyConf.Apps.Http.Servers.Myserver.Routes)[0].Id, "route '0' should still be at the same position")
-	a.Equal(1, len(*caddyConf.Apps.Http.Servers.Myserver.Routes), "should contain just one record after re-adding the same route id")
+	st.patchRoute(route0)
+	a.Equal(*st.conf, CaddyConf{}, "adding to empty conf produces empty conf")
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.NotEqual(*st.conf, CaddyConf{}, "initial conf shouldn't result in an empty config")
+	a.Equal(false, st.isCaddyConfEmptyNonBlocking())
+	st.patchRoute(route0)
+	a.NotEqual(st.conf, CaddyConf{}, "adding to non-empty conf produces non-empty conf")
+	a.NotNil(st.conf.Apps.Http.Servers["myserver"].Routes, "after adding a route can't be nil")
+	a.Equal("0", (*st.conf.Apps.Http.Servers["myserver"].Routes)[0].Id)
+	st.patchRoute(route0) // Re-adding the same route
+	a.Equal("0", (*st.conf.Apps.Http.Servers["myserver"].Routes)[0].Id, "route '0' should still be at the same position")
+	a.Equal(1, len(*st.conf.Apps.Http.Servers["myserver"].Routes), "should contain just one record after re-adding the same route id")
 	route1 := Route{
 		Id:      "1",
 		Handles: nil,
 		Matches: nil,
 	}
-	patchRoute(route1)
-	a.Equal(2, len(*caddyConf.Apps.Http.Servers.Myserver.Routes), "should contain 2 records after adding another route")
-	patchRoute(route0)
-	a.Equal(2, len(*caddyConf.Apps.Http.Servers.Myserver.Routes), "should contain 2 records after re-adding route '0'")
-	a.Equal("0", (*caddyConf.Apps.Http.Servers.Myserver.Routes)[0].Id, "route '0' should still be at the same position")
-	a.Equal("1", (*caddyConf.Apps.Http.Servers.Myserver.Routes)[1].Id, "route '1' should still be at the same position")
+	st.patchRoute(route1)
+	a.Equal(2, len(*st.conf.Apps.Http.Servers["myserver"].Routes), "should contain 2 records after adding another route")
+	st.patchRoute(route0)
+	a.Equal(2, len(*st.conf.Apps.Http.Servers["myserver"].Routes), "should contain 2 records after re-adding route '0'")
+	a.Equal("0", (*st.conf.Apps.Http.Servers["myserver"].Routes)[0].Id, "route '0' should still be at the same position")
+	a.Equal("1", (*st.conf.Apps.Http.Servers["myserver"].Routes)[1].Id, "route '1' should still be at the same position")
 	//r, _ := json.MarshalIndent(caddyConf, "", "  ")
 	//fmt.Println(string(r))
 }
 
 func testAddInvalidRoute(t *testing.T) {
+	t.Parallel()
 	a := assert.New(t)
-	resetConfToMinimumNonEmptyConf()
-	err := AddRoute(&pb.Route{
+	st := newMinimumStore(t)
+	_, err := st.AddRoute(&pb.Route{
 		Id:      "",
 		Handles: nil,
 		Matches: nil,
-	})
+	}, Registration{})
 	a.NotNil(err, "should return error")
+
+	valid := func() *pb.Route {
+		r := routetest.Route("valid")
+		r.Matches = []*pb.Match{{Hosts: []string{"example.com"}, Paths: []string{"/*"}}}
+		return r
+	}
+	proxy := func(r *pb.Route) *pb.ReverseProxy { return r.Handles[0].GetReverseProxy() }
+	a.Nil(validateRoute(valid()))
+	for field, change := range map[string]func(r *pb.Route){
+		"handles[0].handler":                             func(r *pb.Route) { r.Handles[0] = &pb.Handle{} },
+		"handles[0].reverseProxy":                        func(r *pb.Route) { r.Handles[0].Handler = &pb.Handle_ReverseProxy{} },
+		"handles[0].reverseProxy.transport":              func(r *pb.Route) { proxy(r).Transport = nil },
+		"handles[0].reverseProxy.transport.protocol":     func(r *pb.Route) { proxy(r).Transport.Protocol = 42 },
+		"handles[0].reverseProxy.upstreams":              func(r *pb.Route) { proxy(r).Upstreams = nil },
+		"handles[0].reverseProxy.upstreams[0].dial":      func(r *pb.Route) { proxy(r).Upstreams[0].Dial = nil },
+		"handles[0].reverseProxy.upstreams[0].dial.host": func(r *pb.Route) { proxy(r).Upstreams[0].Dial.Host = "" },
+		"handles[0].reverseProxy.upstreams[0].dial.port": func(r *pb.Route) { proxy(r).Upstreams[0].Dial.Port = 0 },
+		"matches[0]":          func(r *pb.Route) { r.Matches[0] = nil },
+		"matches[0].hosts[0]": func(r *pb.Route) { r.Matches[0].Hosts[0] = "" },
+		"matches[0].paths[0]": func(r *pb.Route) { r.Matches[0].Paths[0] = "api/*" },
+	} {
+		r := valid()
+		change(r)
+		var fe *FieldError
+		if a.ErrorAs(errOf(st.AddRoute(r, Registration{})), &fe, field) {
+			a.Equal(field, fe.Field)
+		}
+	}
+	r := valid()
+	proxy(r).Transport.Protocol = 42
+	var fe *FieldError
+	a.ErrorAs(errOf(st.AddRoutes([]*pb.Route{valid(), r}, Registration{})), &fe, "unknown protocol should not stop the server")
+	a.Equal("routes[1].handles[0].reverseProxy.transport.protocol", fe.Field)
 }
 
 func testAddRouteRace(t *testing.T) {
+	t.Parallel()
 	a := assert.New(t)
-	resetConfToMinimumNonEmptyConf()
+	st := newMinimumStore(t)
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
 		go func(idx int) {
-			err := AddRoute(&pb.Route{
+			_, err := st.AddRoute(&pb.Route{
 				Id: strconv.Itoa(idx),
 				Handles: []*pb.Handle{
 					{
@@ -127,13 +203,543 @@ func testAddRouteRace(t *testing.T) {
 						Paths: []string{"/*"},
 					},
 				},
-			})
+			}, Registration{})
 			a.Nil(err, "should be no error")
 			wg.Done()
 		}(i)
 	}
 	wg.Wait()
-	a.Equal(10, len(*caddyConf.Apps.Http.Servers.Myserver.Routes), "should fill every distinct route id")
+	a.Equal(10, len(*st.conf.Apps.Http.Servers["myserver"].Routes), "should fill every distinct route id")
 	//r, _ := json.MarshalIndent(caddyConf, "", "  ")
 	//fmt.Println(string(r))
 }
+
+// routeIn returns route with id added to server.
+func routeIn(id string, server string) *pb.Route {
+	r := routetest.Route(id)
+	r.Server = server
+	return r
+}
+
+func testRemoveRoute(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := newMinimumStore(t)
+	for i := 0; i < 10; i++ {
+		a.Nil(errOf(st.AddRoute(routetest.Route(strconv.Itoa(i)), Registration{})))
+	}
+	a.Equal(10, len(*st.conf.Apps.Http.Servers["myserver"].Routes))
+	_, err := st.RemoveRoute("")
+	a.NotNil(err, "should return error for empty id")
+	removed, err := st.RemoveRoute("3")
+	a.Nil(err)
+	a.NotNil(removed.Before, "route '3' was added before")
+	a.Equal(9, len(*st.conf.Apps.Http.Servers["myserver"].Routes), "should contain one record less")
+	removed, err = st.RemoveRoute("3")
+	a.Nil(err)
+	a.Nil(removed.Before, "route '3' is already removed")
+	a.Equal(9, len(*st.conf.Apps.Http.Servers["myserver"].Routes))
+	for _, r := range *st.conf.Apps.Http.Servers["myserver"].Routes {
+		a.NotEqual("3", r.Id)
+	}
+}
+
+func testExpireRoutes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := newMinimumStore(t)
+	a.Nil(errOf(st.AddRoute(routetest.Route("leased"), Registration{TTL: time.Minute})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("permanent"), Registration{})))
+	a.Empty(st.ExpireRoutes(time.Now()), "lease is not over yet")
+	expired := st.ExpireRoutes(time.Now().Add(time.Hour))
+	a.Equal(1, len(expired))
+	a.Equal("leased", expired[0].Id)
+	a.NotNil(expired[0].Before)
+	a.Nil(expired[0].After)
+	a.Equal(1, len(*st.conf.Apps.Http.Servers["myserver"].Routes))
+	a.Equal("permanent", (*st.conf.Apps.Http.Servers["myserver"].Routes)[0].Id)
+	a.Nil(errOf(st.AddRoute(routetest.Route("permanent"), Registration{TTL: time.Minute})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("permanent"), Registration{})), "re-adding without ttl drops the lease")
+	a.Empty(st.ExpireRoutes(time.Now().Add(time.Hour)))
+	a.Equal(1, len(*st.conf.Apps.Http.Servers["myserver"].Routes))
+}
+
+func testListRoutes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := newMinimumStore(t)
+	route := &pb.Route{
+		Id: "shop.example.com",
+		Handles: []*pb.Handle{
+			{Handler: &pb.Handle_ReverseProxy{ReverseProxy: &pb.ReverseProxy{
+				Transport: &pb.Transport{Protocol: pb.Transport_FastCGI},
+				Upstreams: []*pb.Upstream{{Dial: &pb.Dial{Host: "localhost", Port: 9000}}},
+			}}},
+		},
+		Matches: []*pb.Match{{Hosts: []string{"shop.example.com"}, Paths: []string{"/*"}}},
+		Server:  "myserver",
+	}
+	a.Nil(errOf(st.AddRoute(route, Registration{Peer: "127.0.0.1:5000"})))
+	st.patchRoute(Route{Id: "static.example.com"})
+
+	a.Equal(2, len(st.ListRoutes("", "")))
+	a.Equal(1, len(st.ListRoutes("shop.", "")))
+	a.Equal(0, len(st.ListRoutes("", "other.example.com")))
+	infos := st.ListRoutes("", "shop.example.com")
+	a.Equal(1, len(infos))
+	a.True(proto.Equal(route, infos[0].Route), "should convert back to the added route")
+	a.Equal("127.0.0.1:5000", infos[0].Peer)
+	a.False(infos[0].Registered.IsZero())
+
+	info, ok := st.GetRoute("static.example.com")
+	a.True(ok)
+	a.True(info.Registered.IsZero(), "route was not added with AddRoute")
+	_, ok = st.GetRoute("missing")
+	a.False(ok)
+}
+
+func testRouteEvents(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := newMinimumStore(t)
+	ch, unsubscribe := st.Events().Subscribe(10)
+	defer unsubscribe()
+
+	st.patchRoute(Route{Id: "0"})
+	st.patchRoute(Route{Id: "0"}) // Same route is not a change
+	st.patchRoute(Route{Id: "0", Matches: []Match{{Hosts: []string{"example.com"}}}})
+	st.removeRoute("0")
+	st.removeRoute("0") // Missing route is not a change
+
+	var kinds []events.Kind
+	for len(ch) > 0 {
+		e := <-ch
+		a.Equal("0", e.RouteId)
+		kinds = append(kinds, e.Kind)
+	}
+	a.Equal([]events.Kind{events.RouteAdded, events.RouteUpdated, events.RouteRemoved}, kinds)
+}
+
+func testAddRoutes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := newMinimumStore(t)
+	a.NotNil(errOf(st.AddRoutes([]*pb.Route{routetest.Route("a"), {Id: "b"}}, Registration{})), "route without handles should be rejected")
+	a.Empty(*st.conf.Apps.Http.Servers["myserver"].Routes, "no route should be added")
+	a.NotNil(errOf(st.AddRoutes([]*pb.Route{routetest.Route("a"), routetest.Route("a")}, Registration{})), "duplicate ids should be rejected")
+	a.Empty(*st.conf.Apps.Http.Servers["myserver"].Routes, "no route should be added")
+	a.Nil(errOf(st.AddRoutes([]*pb.Route{routetest.Route("a"), routetest.Route("b")}, Registration{Peer: "peer"})))
+	a.Equal(2, len(*st.conf.Apps.Http.Servers["myserver"].Routes))
+	info, ok := st.GetRoute("b")
+	a.True(ok)
+	a.Equal("peer", info.Peer)
+}
+
+func testRouteChanges(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	port := func(r *Route) string { return r.Handles[0].Upstreams[0].Dial }
+
+	change, err := st.AddRoute(routetest.Route("a"), Registration{})
+	a.Nil(err)
+	a.Equal("a", change.Id)
+	a.Nil(change.Before)
+	a.Equal("localhost:8080", port(change.After), "pending route should be reported")
+	_, err = st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+
+	changed := routetest.Route("a")
+	changed.Handles[0].GetReverseProxy().Upstreams[0].Dial.Port = 9000
+	changes, err := st.AddRoutes([]*pb.Route{changed, routetest.Route("b")}, Registration{})
+	a.Nil(err)
+	a.Equal(2, len(changes))
+	a.Equal("localhost:8080", port(changes[0].Before))
+	a.Equal("localhost:9000", port(changes[0].After))
+	a.Equal("b", changes[1].Id)
+	a.Nil(changes[1].Before)
+	a.NotNil(changes[1].After)
+
+	change, err = st.RemoveRoute("a")
+	a.Nil(err)
+	a.Equal("localhost:9000", port(change.Before))
+	a.Nil(change.After)
+	change, err = st.RemoveRoute("a")
+	a.Nil(err)
+	a.Nil(change.Before, "removed route should not exist")
+}
+
+func testPendingRoutes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	a.Nil(errOf(st.AddRoute(routetest.Route("a"), Registration{})), "route should be held until the conf is received")
+	a.Nil(errOf(st.AddRoutes([]*pb.Route{routetest.Route("b"), routetest.Route("c")}, Registration{})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("a"), Registration{})), "re-adding a pending route replaces it")
+	removed, err := st.RemoveRoute("c")
+	a.Nil(err)
+	a.NotNil(removed.Before, "pending route can be removed")
+	a.Equal(true, st.isCaddyConfEmptyNonBlocking())
+
+	merged, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.Equal([]string{"b", "a"}, merged)
+	a.Equal(2, len(*st.conf.Apps.Http.Servers["myserver"].Routes))
+	a.Empty(st.pending)
+
+	// Routes that do not fit the conf are not taken for ones Caddy rejected
+	st = NewStore()
+	a.Nil(errOf(st.AddRoute(routeIn("lost", "missing"), Registration{})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("static"), Registration{})))
+	a.Nil(st.SetInitialConf(`{"apps":{"http":{"servers":{"myserver":{"listen":[":443"],"routes":[{"@id":"static"}]}}}}}`))
+	merged, err = st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.Empty(merged)
+	var fe *FieldError
+	a.ErrorAs(st.RouteRejection("lost"), &fe)
+	var be *BaseRouteError
+	a.ErrorAs(st.RouteRejection("static"), &be)
+	a.ErrorAs(errOf(st.AddRoute(routetest.Route("static"), Registration{})), &be, "base route should be refused on the next AddRoute")
+	a.Nil(errOf(st.AddRoute(routeIn("lost", ""), Registration{})), "route should be accepted once it fits")
+	a.Nil(st.RouteRejection("lost"))
+}
+
+func testLosslessConf(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	conf := `{
+  "admin": {"listen": "localhost:2019", "origins": ["localhost"]},
+  "logging": {"logs": {"default": {"level": "DEBUG"}}},
+  "apps": {
+    "tls": {"automation": {"policies": [{"issuers": [{"module": "internal"}]}]}},
+    "http": {
+      "grace_period": 10000000000,
+      "servers": {
+        "myserver": {
+          "automatic_https": {"skip": [], "disable_redirects": true},
+          "listen": [":443"],
+          "read_timeout": 5000000000,
+          "routes": [
+            {
+              "@id": "static",
+              "match": [{"host": ["static.example.com"], "header": {"X-Test": ["1"]}}],
+              "handle": [{"handler": "static_response", "body": "hello", "status_code": 200}],
+              "terminal": true
+            },
+            {
+              "@id": "srv",
+              "handle": [{"handler": "reverse_proxy", "upstreams": [{"lookup_srv": "_http._tcp.svc"}]}]
+            },
+            {
+              "@id": "tls",
+              "handle": [{"handler": "reverse_proxy", "transport": {"tls": {}}, "upstreams": [{"dial": "backend:443"}]}]
+            }
+          ]
+        },
+        "other": {"listen": [":8080"], "routes": []}
+      }
+    }
+  }
+}`
+	_, err := st.SetConf([]byte(conf))
+	a.Nil(err)
+	a.Nil(errOf(st.AddRoute(routetest.Route("added"), Registration{})))
+	read, err := st.ReadConf()
+	a.Nil(err)
+
+	var want, got map[string]any
+	a.Nil(json.Unmarshal([]byte(conf), &want))
+	a.Nil(json.Unmarshal([]byte(read), &got))
+	routes := got["apps"].(map[string]any)["http"].(map[string]any)["servers"].(map[string]any)["myserver"].(map[string]any)["routes"].([]any)
+	a.Equal(4, len(routes))
+	base := want["apps"].(map[string]any)["http"].(map[string]any)["servers"].(map[string]any)["myserver"].(map[string]any)["routes"].([]any)
+	a.Equal(base, routes[:3], "base routes should be kept as is")
+	a.Equal("added", routes[3].(map[string]any)["@id"])
+
+	// Everything but the routes should be left untouched
+	delete(want["apps"].(map[string]any)["http"].(map[string]any)["servers"].(map[string]any)["myserver"].(map[string]any), "routes")
+	delete(got["apps"].(map[string]any)["http"].(map[string]any)["servers"].(map[string]any)["myserver"].(map[string]any), "routes")
+	a.Equal(want, got)
+}
+
+func testServers(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	st.SetDefaultServer("public")
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.NotNil(err, "conf without the default server should be refused")
+	_, err = st.SetConf([]byte(`{"apps":{"http":{"servers":{
+		"public":{"listen":[":443"],"routes":[]},
+		"internal":{"listen":[":8443"]}
+	}}}}`))
+	a.Nil(err)
+
+	a.Nil(errOf(st.AddRoute(routeIn("a", ""), Registration{})))
+	a.Nil(errOf(st.AddRoute(routeIn("b", "internal"), Registration{})))
+	var fe *FieldError
+	a.ErrorAs(errOf(st.AddRoute(routeIn("c", "missing"), Registration{})), &fe, "server should exist")
+	a.Equal("server", fe.Field)
+	a.ErrorAs(errOf(st.AddRoutes([]*pb.Route{routeIn("c", ""), routeIn("d", "missing")}, Registration{})), &fe)
+	a.Equal("routes[1].server", fe.Field)
+	_, ok := st.GetRoute("c")
+	a.False(ok, "no route of a failed batch should be added")
+
+	a.Equal("a", (*st.conf.Apps.Http.Servers["public"].Routes)[0].Id)
+	a.Equal("b", (*st.conf.Apps.Http.Servers["internal"].Routes)[0].Id)
+
+	a.Nil(errOf(st.AddRoute(routeIn("a", "internal"), Registration{})), "route can move to another server")
+	a.Empty(*st.conf.Apps.Http.Servers["public"].Routes)
+	a.Equal(2, len(*st.conf.Apps.Http.Servers["internal"].Routes))
+	info, ok := st.GetRoute("a")
+	a.True(ok)
+	a.Equal("internal", info.Route.Server)
+
+	removed, err := st.RemoveRoute("b")
+	a.Nil(err)
+	a.NotNil(removed.Before)
+	a.Equal(1, len(*st.conf.Apps.Http.Servers["internal"].Routes))
+}
+
+func testReconcileConf(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	a.Nil(st.SetInitialConf(`{"apps":{"http":{"servers":{
+		"myserver":{"listen":[":443"],"routes":[{"@id":"static"}]},
+		"internal":{"listen":[":8443"]}
+	}}}}`))
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.Nil(errOf(st.AddRoute(routeIn("a", ""), Registration{})))
+	a.Nil(errOf(st.AddRoute(routeIn("b", "internal"), Registration{})))
+
+	// Caddy restarted with a changed base conf, lost b and kept an old copy of a
+	a.Nil(st.ReconcileConf([]byte(`{"admin":{"listen":":2019"},"apps":{"http":{"servers":{
+		"myserver":{"listen":[":443"],"routes":[{"@id":"a"},{"@id":"static2"}]},
+		"internal":{"listen":[":9443"]}
+	}}}}`)))
+	var ids []string
+	for _, r := range st.ListRoutes("", "") {
+		ids = append(ids, r.Route.Id)
+	}
+	a.Equal([]string{"b", "static2", "a"}, ids, "managed routes should follow the base routes of live conf")
+	info, _ := st.GetRoute("a")
+	a.Equal(uint32(8080), info.Route.Handles[0].GetReverseProxy().Upstreams[0].Dial.Port)
+	conf, err := st.ReadConf()
+	a.Nil(err)
+	a.Contains(conf, `"admin":{"listen":":2019"}`)
+	a.Contains(conf, `":9443"`)
+
+	a.Nil(st.ReconcileConf([]byte(`{"apps":{"http":{"servers":{"myserver":{"listen":[":443"]}}}}}`)))
+	_, ok := st.GetRoute("b")
+	a.False(ok, "route of a removed server should be dropped")
+	var fe *FieldError
+	a.ErrorAs(st.RouteRejection("b"), &fe, "dropped route should be reported")
+	a.Equal("server", fe.Field)
+	a.ErrorAs(errOf(st.AddRoute(routeIn("b", "internal"), Registration{})), &fe, "server should exist")
+	_, ok = st.GetRoute("a")
+	a.True(ok)
+	a.Nil(st.ReconcileConf([]byte(`{"apps":{"http":{"servers":{"myserver":{"listen":[":443"]},"internal":{"listen":[":8443"]}}}}}`)))
+	a.Nil(errOf(st.AddRoute(routeIn("b", "internal"), Registration{})), "route can be added once its server is back")
+	a.Nil(st.RouteRejection("b"))
+
+	a.NotNil(st.ReconcileConf([]byte(`{"apps":{"http":{"servers":{"other":{"listen":[":443"]}}}}}`)), "default server should exist")
+}
+
+func testOwnership(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	ids := func() []string {
+		var ids []string
+		for _, r := range *st.conf.Apps.Http.Servers["myserver"].Routes {
+			ids = append(ids, r.Id)
+		}
+		return ids
+	}
+	st.SetOwnership(Ownership{Prefix: "inj-", First: true})
+	a.Nil(st.SetInitialConf(`{"apps":{"http":{"servers":{"myserver":{"listen":[":443"],"routes":[
+		{"@id":"static"},{"@id":"inj-left"},{"@id":"api.example.com"}
+	]}}}}}`))
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.Equal([]string{"inj-left", "static", "api.example.com"}, ids(), "routes with the prefix are owned")
+
+	var be *BaseRouteError
+	a.ErrorAs(errOf(st.AddRoute(routetest.Route("api.example.com"), Registration{})), &be, "static route should not be replaced")
+	a.Equal("api.example.com", be.Id)
+	_, err = st.AddRoutes([]*pb.Route{routetest.Route("app"), routetest.Route("static")}, Registration{})
+	a.ErrorAs(err, &be)
+	a.Equal("static", be.Id)
+	a.Contains(err.Error(), "routes[1]")
+	_, err = st.RemoveRoute("static")
+	a.ErrorAs(err, &be, "static route should not be removed")
+
+	a.Nil(errOf(st.AddRoute(routetest.Route("app"), Registration{})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("inj-left"), Registration{})))
+	a.Equal([]string{"inj-left", "app", "static", "api.example.com"}, ids())
+	removed, err := st.RemoveRoute("inj-left")
+	a.Nil(err)
+	a.NotNil(removed.Before)
+
+	st.SetOwnership(Ownership{AllowOverride: true})
+	a.Nil(errOf(st.AddRoute(routetest.Route("api.example.com"), Registration{})), "overriding can be allowed")
+	a.Equal([]string{"static", "app", "api.example.com"}, ids(), "overridden route is owned")
+	removed, err = st.RemoveRoute("static")
+	a.Nil(err)
+	a.NotNil(removed.Before)
+}
+
+func testOwnershipRestart(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	base := `{"apps":{"http":{"servers":{"myserver":{"listen":[":443"],"routes":[{"@id":"static"}]}}}}}`
+	st := NewStore()
+	a.Nil(st.SetInitialConf(base))
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.Nil(errOf(st.AddRoute(routetest.Route("app"), Registration{})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("gone"), Registration{})))
+	conf, err := st.ReadConf()
+	a.Nil(err)
+
+	// Restart without a state dir, Caddy still holding the routes
+	st = NewStore()
+	a.Nil(st.SetInitialConf(base))
+	merged, err := st.SetConf([]byte(conf))
+	a.Nil(err)
+	a.Empty(merged)
+	info, ok := st.GetRoute("app")
+	a.True(ok)
+	a.False(info.Registered.IsZero(), "route left by the previous run should be owned")
+	a.Nil(errOf(st.AddRoute(routetest.Route("app"), Registration{TTL: time.Hour})), "owner should be able to announce the route again")
+	removed, err := st.RemoveRoute("gone")
+	a.Nil(err)
+	a.NotNil(removed.Before)
+	var be *BaseRouteError
+	a.ErrorAs(errOf(st.RemoveRoute("static")), &be, "route of the base conf should stay protected")
+}
+
+func TestBaseConf(t *testing.T) {
+	a := assert.New(t)
+	st := NewStore()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "base.json")
+	a.Nil(os.WriteFile(path, []byte(`{"apps":{"http":{"servers":{"myserver":{"listen":["${ LISTEN }"],"routes":[{"@id":"${ID}"}]}}}}}`), 0o600))
+	values := filepath.Join(dir, "values.env")
+	a.Nil(os.WriteFile(values, []byte("# base conf\nLISTEN = :8443\n"), 0o600))
+
+	_, err := LoadBaseConf(path, values)
+	a.ErrorContains(err, "no value for ${ID}")
+	t.Setenv("ID", "static")
+	t.Setenv("LISTEN", ":80")
+	conf, err := LoadBaseConf(path, values)
+	a.Nil(err)
+	a.Equal(`{"apps":{"http":{"servers":{"myserver":{"listen":[":8443"],"routes":[{"@id":"static"}]}}}}}`, conf, "values file should win over environment")
+
+	a.Nil(st.SetInitialConf(conf))
+	a.Equal(conf, st.InitialConfSrc())
+	a.NotNil(st.SetInitialConf(`{"apps":`), "invalid json should be refused")
+	a.NotNil(st.SetInitialConf(`{"apps":{"http":{"servers":{"other":{"listen":[":443"]}}}}}`), "default server should exist")
+	a.Equal(conf, st.InitialConfSrc())
+}
+
+func testPersistState(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	dir := t.TempDir()
+	a.Nil(st.SetStateDir(dir), "missing state is not an error")
+	a.Nil(errOf(st.AddRoute(routetest.Route("early"), Registration{})))
+	_, err := st.SetConf([]byte(`{"apps":{"http":{"servers":{"myserver":{"listen":[":443"],"routes":[{"@id":"static"}]}}}}}`))
+	a.Nil(err)
+	a.Nil(errOf(st.AddRoute(routetest.Route("leased"), Registration{Peer: "127.0.0.1:5000", TTL: time.Hour})))
+	before, _ := st.GetRoute("leased")
+	conf, err := st.ReadConf()
+	a.Nil(err)
+
+	// Restart with Caddy still holding the routes
+	st = NewStore()
+	a.Nil(st.SetStateDir(dir))
+	merged, err := st.SetConf([]byte(conf))
+	a.Nil(err)
+	a.Equal([]string{"early", "leased"}, merged)
+	after, err := st.ReadConf()
+	a.Nil(err)
+	a.Equal(conf, after, "restart should not change the conf")
+	info, ok := st.GetRoute("leased")
+	a.True(ok)
+	a.Equal("127.0.0.1:5000", info.Peer)
+	a.False(info.Expires.Before(before.Expires), "lease should be kept")
+	a.True(info.Expires.Before(time.Now().Add(time.Hour+time.Minute)), "lease should be renewed for its ttl")
+	a.True(before.Registered.Equal(info.Registered))
+
+	// Refreshing a lease does not rewrite the state
+	a.Nil(os.Remove(filepath.Join(dir, stateFile)))
+	a.Nil(errOf(st.AddRoute(routetest.Route("leased"), Registration{Peer: "127.0.0.1:5000", TTL: time.Hour})))
+	_, err = os.Stat(filepath.Join(dir, stateFile))
+	a.ErrorIs(err, fs.ErrNotExist)
+
+	removed, err := st.RemoveRoute("early")
+	a.Nil(err)
+	a.NotNil(removed.Before)
+	b, err := os.ReadFile(filepath.Join(dir, stateFile))
+	a.Nil(err)
+	var saved []savedRoute
+	a.Nil(json.Unmarshal(b, &saved))
+	a.Equal(1, len(saved))
+	a.Equal("leased", saved[0].Route.Id)
+	a.Equal("myserver", saved[0].Server)
+	a.Equal(time.Hour, saved[0].TTL)
+
+	st = NewStore()
+	a.Nil(os.WriteFile(filepath.Join(dir, stateFile), []byte("{"), 0o600))
+	a.NotNil(st.SetStateDir(dir), "broken state should be reported")
+}
+
+func testRestoreConf(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	st := NewStore()
+	a.Nil(st.SetInitialConf(`{"apps":{"http":{"servers":{"myserver":{"listen":[":443"],"routes":[{"@id":"static"},{"@id":"operator"}]}}}}}`))
+	_, err := st.SetConf([]byte(st.InitialConfSrc()))
+	a.Nil(err)
+	a.Nil(errOf(st.AddRoute(routetest.Route("kept"), Registration{Peer: "127.0.0.1:5000", TTL: time.Hour})))
+	a.Nil(errOf(st.AddRoute(routetest.Route("removed"), Registration{})))
+	old, err := st.ReadConf()
+	a.Nil(err)
+	owned := st.OwnedIds()
+	a.Equal([]string{"kept", "removed"}, owned)
+	_, err = st.RemoveRoute("removed")
+	a.Nil(err)
+	a.Nil(errOf(st.AddRoute(routetest.Route("added"), Registration{})))
+	// Another writer removed a base route meanwhile
+	live, err := st.ReadConf()
+	a.Nil(err)
+	a.Nil(st.ReconcileConf([]byte(strings.Replace(live, `{"@id":"operator"},`, "", 1))))
+	_, ok := st.GetRoute("operator")
+	a.False(ok)
+
+	a.Nil(st.RestoreConf([]byte(old), owned, Registration{TTL: time.Minute}))
+	conf, err := st.ReadConf()
+	a.Nil(err)
+	a.Equal(old, conf)
+	_, ok = st.GetRoute("added")
+	a.False(ok, "route added since should be removed")
+	info, _ := st.GetRoute("kept")
+	a.Equal("127.0.0.1:5000", info.Peer, "registration should be kept")
+	a.True(info.Expires.After(time.Now().Add(time.Minute)), "lease should be kept")
+	info, _ = st.GetRoute("removed")
+	a.False(info.Registered.IsZero(), "restored route should be owned")
+	a.False(info.Expires.IsZero(), "restored route should get the lease of reg")
+	a.True(info.Expires.Before(time.Now().Add(time.Minute + time.Second)))
+	info, ok = st.GetRoute("operator")
+	a.True(ok)
+	a.True(info.Registered.IsZero(), "restored base route should not be owned")
+	var be *BaseRouteError
+	a.ErrorAs(errOf(st.RemoveRoute("operator")), &be)
+
+	a.NotNil(st.RestoreConf([]byte(`{"apps":{"http":{"servers":{"other":{"listen":[":443"]}}}}}`), nil, Registration{}))
+}
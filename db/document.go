@@ -0,0 +1,94 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// parseDoc unmarshals conf keeping numbers as they are.
+func parseDoc(conf []byte) (map[string]any, error) {
+	d := json.NewDecoder(bytes.NewReader(conf))
+	d.UseNumber()
+	var doc map[string]any
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// withRoutes returns doc with routes of the server replaced. Objects along
+// the path to the server are copied, so that doc itself is not modified.
+func withRoutes(doc map[string]any, server string, routes []Route) (map[string]any, error) {
+	root := copyObject(doc)
+	o := root
+	for _, k := range []string{"apps", "http", "servers", server} {
+		next, ok := o[k].(map[string]any)
+		if !ok && o[k] != nil {
+			return nil, fmt.Errorf("conf %v is not an object", k)
+		}
+		next = copyObject(next)
+		o[k] = next
+		o = next
+	}
+	if routes == nil {
+		routes = []Route{}
+	}
+	o["routes"] = routes
+	return root, nil
+}
+
+func copyObject(o map[string]any) map[string]any {
+	c := make(map[string]any, len(o))
+	for k, v := range o {
+		c[k] = v
+	}
+	return c
+}
+
+// ConfRoutes returns routes of every server in conf, ordered by server name
+// and then by their position.
+func ConfRoutes(conf string) ([]Route, error) {
+	var c CaddyConf
+	if err := json.Unmarshal([]byte(conf), &c); err != nil {
+		return nil, err
+	}
+	assignServers(&c)
+	var routes []Route
+	for _, name := range serverNames(&c) {
+		if s := c.Apps.Http.Servers[name]; s != nil && s.Routes != nil {
+			routes = append(routes, *s.Routes...)
+		}
+	}
+	return routes, nil
+}
+
+// ConfWithRoutes returns conf with routes of its servers replaced by routes
+// belonging to them, keeping the rest of conf intact.
+func ConfWithRoutes(conf string, routes []Route) (string, error) {
+	var c CaddyConf
+	if err := json.Unmarshal([]byte(conf), &c); err != nil {
+		return "", err
+	}
+	doc, err := parseDoc([]byte(conf))
+	if err != nil {
+		return "", err
+	}
+	for _, name := range serverNames(&c) {
+		var serverRoutes []Route
+		for _, r := range routes {
+			if r.Server == name {
+				serverRoutes = append(serverRoutes, r)
+			}
+		}
+		doc, err = withRoutes(doc, name, serverRoutes)
+		if err != nil {
+			return "", err
+		}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyConf is returned while no conf has been received from Caddy yet.
+var ErrEmptyConf = errors.New("empty config")
+
+// FieldError reports an invalid field of a request.
+type FieldError struct {
+	// Field is a path to the field, e.g. "routes[1].id"
+	Field       string
+	Description string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Field, e.Description)
+}
+
+func fieldErrorf(field string, format string, a ...any) error {
+	return &FieldError{Field: field, Description: fmt.Sprintf(format, a...)}
+}
+
+// prefixFieldError prepends prefix to the Field of err if it is a FieldError.
+func prefixFieldError(prefix string, err error) error {
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return &FieldError{Field: prefix + "." + fe.Field, Description: fe.Description}
+	}
+	return fmt.Errorf("%v: %w", prefix, err)
+}
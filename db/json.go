@@ -1,42 +1,161 @@
 package db
 
+import (
+	"encoding/json"
+)
+
+// CaddyConf is the part of Caddy's conf the injector reads and manages.
+// The rest of the conf is kept as received, see Store.ReadConf.
 type CaddyConf struct {
 	Apps struct {
 		Http struct {
-			Servers struct {
-				Myserver struct {
-					AutomaticHttps struct {
-						Skip []string `json:"skip"`
-					} `json:"automatic_https"`
-					Listen []string `json:"listen"`
-					Routes *[]Route `json:"routes"`
-				} `json:"myserver"`
-			} `json:"servers"`
+			Servers map[string]*Server `json:"servers"`
 		} `json:"http"`
 	} `json:"apps"`
 }
 
+// Server is an HTTP server of Caddy's conf, apps.http.servers.<name>.
+type Server struct {
+	AutomaticHttps struct {
+		Skip []string `json:"skip"`
+	} `json:"automatic_https"`
+	Listen []string `json:"listen"`
+	Routes *[]Route `json:"routes"`
+}
+
+// Extra holds JSON fields a type does not know about, so that they survive
+// a round trip through it.
+type Extra map[string]json.RawMessage
+
 type Upstream struct {
-	Dial string `json:"dial"`
+	Dial  string `json:"dial,omitempty"`
+	Extra Extra  `json:"-"`
 }
 
 type Transport struct {
-	Protocol string `json:"protocol"`
+	Protocol string `json:"protocol,omitempty"`
+	Extra    Extra  `json:"-"`
 }
 
 type Handle struct {
 	Handler   string     `json:"handler"`
 	Transport Transport  `json:"transport"`
-	Upstreams []Upstream `json:"upstreams"`
+	Upstreams []Upstream `json:"upstreams,omitempty"`
+	Extra     Extra      `json:"-"`
 }
 
 type Match struct {
-	Hosts []string `json:"host"`
-	Paths []string `json:"path"`
+	Hosts []string `json:"host,omitempty"`
+	Paths []string `json:"path,omitempty"`
+	Extra Extra    `json:"-"`
 }
 
 type Route struct {
-	Id      string   `json:"@id"`
-	Handles []Handle `json:"handle"`
-	Matches []Match  `json:"match"`
+	Id      string   `json:"@id,omitempty"`
+	Handles []Handle `json:"handle,omitempty"`
+	Matches []Match  `json:"match,omitempty"`
+	Extra   Extra    `json:"-"`
+	// Server is the name of the server the route belongs to
+	Server string `json:"-"`
+}
+
+// unmarshalWithExtra unmarshals b into v, which points to a type without
+// custom unmarshaling, and returns fields of b other than known.
+func unmarshalWithExtra(b []byte, v any, known ...string) (Extra, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	var all Extra
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for _, k := range known {
+		delete(all, k)
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+// marshalWithExtra marshals v, which is a type without custom marshaling,
+// adding fields of extra it does not set itself and dropping fields in omit.
+func marshalWithExtra(v any, extra Extra, omit ...string) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 && len(omit) == 0 {
+		return b, err
+	}
+	var all Extra
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for _, k := range omit {
+		delete(all, k)
+	}
+	for k, raw := range extra {
+		if _, ok := all[k]; !ok {
+			all[k] = raw
+		}
+	}
+	return json.Marshal(all)
+}
+
+func (u *Upstream) UnmarshalJSON(b []byte) (err error) {
+	type plain Upstream
+	u.Extra, err = unmarshalWithExtra(b, (*plain)(u), "dial")
+	return err
+}
+
+func (u Upstream) MarshalJSON() ([]byte, error) {
+	type plain Upstream
+	return marshalWithExtra(plain(u), u.Extra)
+}
+
+func (t *Transport) UnmarshalJSON(b []byte) (err error) {
+	type plain Transport
+	t.Extra, err = unmarshalWithExtra(b, (*plain)(t), "protocol")
+	return err
+}
+
+func (t Transport) MarshalJSON() ([]byte, error) {
+	type plain Transport
+	return marshalWithExtra(plain(t), t.Extra)
+}
+
+func (h *Handle) UnmarshalJSON(b []byte) (err error) {
+	type plain Handle
+	h.Extra, err = unmarshalWithExtra(b, (*plain)(h), "handler", "transport", "upstreams")
+	return err
+}
+
+func (h Handle) MarshalJSON() ([]byte, error) {
+	type plain Handle
+	var omit []string
+	if h.Transport.Protocol == "" && len(h.Transport.Extra) == 0 {
+		// Handlers other than reverse_proxy do not accept transport
+		omit = append(omit, "transport")
+	}
+	return marshalWithExtra(plain(h), h.Extra, omit...)
+}
+
+func (m *Match) UnmarshalJSON(b []byte) (err error) {
+	type plain Match
+	m.Extra, err = unmarshalWithExtra(b, (*plain)(m), "host", "path")
+	return err
+}
+
+func (m Match) MarshalJSON() ([]byte, error) {
+	type plain Match
+	return marshalWithExtra(plain(m), m.Extra)
+}
+
+func (r *Route) UnmarshalJSON(b []byte) (err error) {
+	type plain Route
+	r.Extra, err = unmarshalWithExtra(b, (*plain)(r), "@id", "handle", "match")
+	return err
+}
+
+func (r Route) MarshalJSON() ([]byte, error) {
+	type plain Route
+	return marshalWithExtra(plain(r), r.Extra)
 }
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"time"
+)
+
+func (st *Store) hasRouteNonBlocking(id string) bool {
+	_, ok := st.findRouteNonBlocking(id)
+	return ok
+}
+
+// ExpireRoutes removes every route whose lease ended before now and returns
+// the changes, see Server.SweepExpiredRoutes of the server package.
+func (st *Store) ExpireRoutes(now time.Time) []RouteChange {
+	st.mu.Lock()
+	var expired []RouteChange
+	for id, m := range st.meta {
+		if !m.expires.IsZero() && now.After(m.expires) {
+			expired = append(expired, RouteChange{Id: id, Before: st.lookupRouteNonBlocking(id)})
+		}
+	}
+	for _, c := range expired {
+		st.removeRouteNonBlocking(c.Id)
+	}
+	st.mu.Unlock()
+	if len(expired) > 0 {
+		st.saveState()
+	}
+	return expired
+}
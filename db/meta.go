@@ -0,0 +1,104 @@
+package db
+
+import (
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Registration describes who adds a route and for how long.
+type Registration struct {
+	// Peer is the address of the client that sent the route
+	Peer string
+	// TTL is the lease of the route, see ExpireRoutes. Zero keeps the route
+	// until it is removed explicitly.
+	TTL time.Duration
+}
+
+// routeMeta describes how a route managed by the injector was registered.
+type routeMeta struct {
+	registered time.Time
+	peer       string
+	// expires is zero for routes without a lease
+	expires time.Time
+	ttl     time.Duration
+}
+
+// registerRouteNonBlocking records reg for an existing route and (re)starts its lease.
+func (st *Store) registerRouteNonBlocking(id string, reg Registration) {
+	if !st.hasRouteNonBlocking(id) {
+		delete(st.meta, id)
+		return
+	}
+	now := time.Now()
+	m := &routeMeta{
+		registered: now,
+		peer:       reg.Peer,
+	}
+	if reg.TTL > 0 {
+		m.expires = now.Add(reg.TTL)
+		m.ttl = reg.TTL
+	}
+	st.meta[id] = m
+}
+
+// RouteInfo is a route of the conf along with its registration details.
+// Registered and Peer are empty for routes that came with the base conf.
+type RouteInfo struct {
+	Route      *pb.Route
+	Registered time.Time
+	Peer       string
+	// Expires is zero for routes without a lease
+	Expires time.Time
+}
+
+func (st *Store) routeInfoNonBlocking(r Route) RouteInfo {
+	info := RouteInfo{Route: routeToProto(r)}
+	if m, ok := st.meta[r.Id]; ok {
+		info.Registered = m.registered
+		info.Peer = m.peer
+		info.Expires = m.expires
+	}
+	return info
+}
+
+// ListRoutes returns routes of every server of the conf. Routes are filtered by
+// idPrefix and, unless host is empty, by having a match for host.
+func (st *Store) ListRoutes(idPrefix, host string) []RouteInfo {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var infos []RouteInfo
+	for _, r := range st.routesNonBlocking() {
+		if !strings.HasPrefix(r.Id, idPrefix) {
+			continue
+		}
+		if host != "" && !slices.ContainsFunc(r.Matches, func(m Match) bool {
+			return slices.Contains(m.Hosts, host)
+		}) {
+			continue
+		}
+		infos = append(infos, st.routeInfoNonBlocking(r))
+	}
+	return infos
+}
+
+// GetRoute returns the route with the given id and whether it exists.
+func (st *Store) GetRoute(id string) (RouteInfo, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if r, ok := st.findRouteNonBlocking(id); ok {
+		return st.routeInfoNonBlocking(r), true
+	}
+	return RouteInfo{}, false
+}
+
+// FindRoute returns the route with the given id as it is sent to Caddy and
+// whether it exists. Routes pending until the conf is received are not found.
+func (st *Store) FindRoute(id string) (Route, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.findRouteNonBlocking(id)
+}
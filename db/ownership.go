@@ -0,0 +1,121 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Ownership configures which routes of the conf belong to the injector.
+// Routes added by clients are owned, others came with the base conf of Caddy.
+type Ownership struct {
+	// Prefix marks routes with ids starting with it as owned, for example
+	// routes left in Caddy by a previous run. Empty marks none.
+	Prefix string
+	// AllowOverride lets clients replace or remove routes of the base conf,
+	// which are owned from then on
+	AllowOverride bool
+	// First keeps owned routes before routes of the base conf instead of after
+	First bool
+}
+
+// BaseRouteError is returned when a client attempts to replace or remove a
+// route of the base conf while overriding is not allowed.
+type BaseRouteError struct {
+	Id string
+}
+
+func (e *BaseRouteError) Error() string {
+	return fmt.Sprintf("route %q belongs to the base conf of caddy", e.Id)
+}
+
+// SetOwnership sets which routes the injector manages and where they are
+// kept. Expected to be called before the conf is set.
+func (st *Store) SetOwnership(o Ownership) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.ownership = o
+}
+
+// isOwnedNonBlocking reports whether the route with id is managed by the injector.
+func (st *Store) isOwnedNonBlocking(id string) bool {
+	if _, ok := st.meta[id]; ok {
+		return true
+	}
+	return id != "" && st.ownership.Prefix != "" && strings.HasPrefix(id, st.ownership.Prefix)
+}
+
+// checkOwnedNonBlocking returns BaseRouteError if the route with id came with
+// the base conf and overriding it is not allowed.
+func (st *Store) checkOwnedNonBlocking(id string) error {
+	if st.ownership.AllowOverride || st.isOwnedNonBlocking(id) {
+		return nil
+	}
+	if _, ok := st.findRouteNonBlocking(id); ok {
+		return &BaseRouteError{Id: id}
+	}
+	return nil
+}
+
+// OwnedIds returns ids of routes of the conf managed by the injector.
+func (st *Store) OwnedIds() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var ids []string
+	for _, r := range st.routesNonBlocking() {
+		if st.isOwnedNonBlocking(r.Id) {
+			ids = append(ids, r.Id)
+		}
+	}
+	return ids
+}
+
+// ownLeftoversNonBlocking takes ownership of routes of the conf that have an
+// id and are not part of the initial conf, which a previous run that did not
+// save its state has added.
+func (st *Store) ownLeftoversNonBlocking() {
+	base := map[string]bool{}
+	for _, s := range st.InitialConf().Apps.Http.Servers {
+		if s != nil && s.Routes != nil {
+			for _, r := range *s.Routes {
+				base[r.Id] = true
+			}
+		}
+	}
+	var ids []string
+	for _, r := range st.routesNonBlocking() {
+		if r.Id != "" && !base[r.Id] && !st.isOwnedNonBlocking(r.Id) {
+			st.meta[r.Id] = &routeMeta{registered: time.Now()}
+			ids = append(ids, r.Id)
+		}
+	}
+	if len(ids) > 0 {
+		slog.Info("routes left by a previous run taken over", "ids", ids)
+	}
+}
+
+// arrangeNonBlocking keeps owned routes of every server grouped before or
+// after the other routes, preserving the order within each group.
+func (st *Store) arrangeNonBlocking() {
+	for _, s := range st.conf.Apps.Http.Servers {
+		if s == nil || s.Routes == nil {
+			continue
+		}
+		own, base := []Route{}, []Route{}
+		for _, r := range *s.Routes {
+			if st.isOwnedNonBlocking(r.Id) {
+				own = append(own, r)
+			} else {
+				base = append(base, r)
+			}
+		}
+		var routes []Route
+		if st.ownership.First {
+			routes = append(own, base...)
+		} else {
+			routes = append(base, own...)
+		}
+		s.Routes = &routes
+	}
+}
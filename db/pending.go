@@ -0,0 +1,82 @@
+package db
+
+import (
+	"time"
+)
+
+// pendingRoute is a route added before the conf was received from Caddy.
+// meta is set for routes restored by SetStateDir instead of reg.
+type pendingRoute struct {
+	route  Route
+	reg    Registration
+	queued time.Time
+	meta   *routeMeta
+}
+
+// pendingMeta returns routeMeta the route gets once merged.
+func (p pendingRoute) pendingMeta() routeMeta {
+	if p.meta != nil {
+		return *p.meta
+	}
+	m := routeMeta{registered: p.queued, peer: p.reg.Peer}
+	if p.reg.TTL > 0 {
+		m.expires = p.queued.Add(p.reg.TTL)
+		m.ttl = p.reg.TTL
+	}
+	return m
+}
+
+// queueRouteNonBlocking holds r until the conf is received, replacing an
+// earlier pending route with the same id.
+func (st *Store) queueRouteNonBlocking(r Route, reg Registration) {
+	st.dropPendingNonBlocking(r.Id)
+	st.pending = append(st.pending, pendingRoute{route: r, reg: reg, queued: time.Now()})
+}
+
+// dropPendingNonBlocking removes the pending route with the id and reports
+// whether it existed.
+func (st *Store) dropPendingNonBlocking(id string) bool {
+	for i, p := range st.pending {
+		if p.route.Id == id {
+			st.pending = append(st.pending[:i], st.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// mergePendingNonBlocking adds pending routes to the conf and returns their
+// ids. Routes whose lease ran out while waiting are dropped, as are routes
+// that do not fit the conf, see RouteRejection.
+func (st *Store) mergePendingNonBlocking() []string {
+	var merged []string
+	now := time.Now()
+	for _, p := range st.pending {
+		if m := p.pendingMeta(); !m.expires.IsZero() && now.After(m.expires) {
+			continue
+		}
+		if st.checkRejectedNonBlocking(p.route) != nil {
+			continue
+		}
+		if p.meta != nil {
+			// Restored routes are owned, even if they made it into the base conf
+			st.meta[p.route.Id] = p.meta
+		}
+		err := st.checkOwnedNonBlocking(p.route.Id)
+		if err == nil {
+			err = st.patchRouteNonBlocking(p.route)
+		}
+		if err != nil {
+			// Not Caddy's fault, the owner gets the error again on the next AddRoute
+			delete(st.meta, p.route.Id)
+			st.dropped[p.route.Id] = err
+			continue
+		}
+		if p.meta == nil {
+			st.registerRouteNonBlocking(p.route.Id, p.reg)
+		}
+		merged = append(merged, p.route.Id)
+	}
+	st.pending = nil
+	return merged
+}
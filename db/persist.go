@@ -0,0 +1,151 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const stateFile = "routes.json"
+
+// savedRoute is an owned route along with its routeMeta as kept in stateFile.
+type savedRoute struct {
+	Route      Route     `json:"route"`
+	Server     string    `json:"server"`
+	Registered time.Time `json:"registered"`
+	Peer       string    `json:"peer,omitempty"`
+	// Expires is zero for routes without a lease
+	Expires time.Time `json:"expires"`
+	// TTL is the lease the route was added with
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// SetStateDir makes routes added by clients persist in dir across restarts.
+// Routes saved by a previous run are held pending until the conf is received
+// from Caddy, as if they were just added, and every change is saved from then on.
+//
+// Leases refreshed by clients adding the same route again are not saved, so
+// leases of restored routes are renewed for their TTL instead.
+func (st *Store) SetStateDir(dir string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	b, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		var saved []savedRoute
+		if err := json.Unmarshal(b, &saved); err != nil {
+			return fmt.Errorf("%v: %v", stateFile, err)
+		}
+		now := time.Now()
+		for _, s := range saved {
+			s.Route.Server = s.Server
+			if s.TTL > 0 && s.Expires.Before(now.Add(s.TTL)) {
+				s.Expires = now.Add(s.TTL)
+			}
+			st.pending = append(st.pending, pendingRoute{
+				route:  s.Route,
+				queued: s.Registered,
+				meta:   &routeMeta{registered: s.Registered, peer: s.Peer, expires: s.Expires, ttl: s.TTL},
+			})
+		}
+		slog.Info("routes restored", "count", len(saved), "dir", dir)
+	}
+	st.stateDir = dir
+	return nil
+}
+
+// saveState writes owned and pending routes to stateFile, replacing it
+// atomically, unless only their registration times changed since the last
+// save. It is expected to be deferred before locking st.mu, so that the file
+// is written once st.mu is released. Failures are logged, since the routes
+// still reach Caddy.
+func (st *Store) saveState() {
+	st.saveMutex.Lock()
+	defer st.saveMutex.Unlock()
+	st.mu.Lock()
+	dir := st.stateDir
+	saved := st.savedRoutesNonBlocking()
+	st.mu.Unlock()
+	if dir == "" {
+		return
+	}
+
+	key, err := json.Marshal(withoutTimes(saved))
+	if err != nil {
+		slog.Error("unable to save routes", "dir", dir, "err", err)
+		return
+	}
+	if string(key) == st.savedKey {
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(dir, stateFile), saved); err != nil {
+		slog.Error("unable to save routes", "dir", dir, "err", err)
+		return
+	}
+	st.savedKey = string(key)
+}
+
+// savedRoutesNonBlocking returns owned and pending routes as kept in stateFile.
+func (st *Store) savedRoutesNonBlocking() []savedRoute {
+	if st.stateDir == "" {
+		return nil
+	}
+	saved := []savedRoute{}
+	for _, r := range st.routesNonBlocking() {
+		if m, ok := st.meta[r.Id]; ok {
+			saved = append(saved, m.saved(r))
+		}
+	}
+	for _, p := range st.pending {
+		saved = append(saved, p.pendingMeta().saved(p.route))
+	}
+	return saved
+}
+
+func (m routeMeta) saved(r Route) savedRoute {
+	return savedRoute{Route: r, Server: r.Server, Registered: m.registered, Peer: m.peer, Expires: m.expires, TTL: m.ttl}
+}
+
+// withoutTimes returns saved with registration times cleared, which change
+// whenever a client adds the same route again.
+func withoutTimes(saved []savedRoute) []savedRoute {
+	var res []savedRoute
+	for _, s := range saved {
+		s.Registered, s.Expires = time.Time{}, time.Time{}
+		res = append(res, s)
+	}
+	return res
+}
+
+// writeFileAtomic writes v as JSON to a temporary file renamed to path, so
+// that path holds either the previous or the new content.
+func writeFileAtomic(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
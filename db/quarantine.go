@@ -0,0 +1,76 @@
+package db
+
+import (
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/events"
+	"reflect"
+)
+
+// rejectedRoute is a route Caddy refused to load.
+type rejectedRoute struct {
+	route  Route
+	reason string
+}
+
+// RouteRejectedError is returned when a route is added again unchanged after
+// Caddy has rejected it.
+type RouteRejectedError struct {
+	Id string
+	// Reason is the error Caddy responded with
+	Reason string
+}
+
+func (e *RouteRejectedError) Error() string {
+	return fmt.Sprintf("route %q was rejected by caddy: %v", e.Id, e.Reason)
+}
+
+// QuarantineRoute remembers that Caddy rejected r with reason and removes r
+// from the conf unless the route with the same id has changed since.
+// Adding the same route again is refused with RouteRejectedError until it
+// changes.
+func (st *Store) QuarantineRoute(r Route, reason string) {
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.rejected[r.Id] = rejectedRoute{route: r, reason: reason}
+	st.events.Publish(events.Event{Kind: events.RouteRejected, RouteId: r.Id, Message: reason})
+	if current, ok := st.findRouteNonBlocking(r.Id); ok && reflect.DeepEqual(current, r) {
+		st.removeRouteNonBlocking(r.Id)
+	}
+}
+
+// checkRejectedNonBlocking refuses r if it is the same route Caddy has
+// rejected before, and forgets the rejection otherwise.
+func (st *Store) checkRejectedNonBlocking(r Route) error {
+	rr, ok := st.rejected[r.Id]
+	if !ok {
+		return nil
+	}
+	if reflect.DeepEqual(rr.route, r) {
+		return &RouteRejectedError{Id: r.Id, Reason: rr.reason}
+	}
+	delete(st.rejected, r.Id)
+	return nil
+}
+
+func (st *Store) findRouteNonBlocking(id string) (Route, bool) {
+	for _, r := range st.routesNonBlocking() {
+		if r.Id == id {
+			return r, true
+		}
+	}
+	return Route{}, false
+}
+
+// RouteRejection returns RouteRejectedError if Caddy rejected the version of
+// the route that was added last, the error AddRoute would return if the
+// route was left out of the conf as it no longer fits it, and nil otherwise.
+func (st *Store) RouteRejection(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if rr, ok := st.rejected[id]; ok {
+		return &RouteRejectedError{Id: id, Reason: rr.reason}
+	}
+	return st.dropped[id]
+}
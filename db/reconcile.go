@@ -0,0 +1,72 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/events"
+)
+
+// ReconcileConf adopts live, the conf currently loaded by Caddy, as the
+// new base conf while keeping routes managed by the injector on top of it.
+//
+// Routes of live are taken as they are, except for owned ones, which Caddy
+// may have lost after a restart or hold an outdated copy of. Managed routes
+// whose server is gone from live are removed, see RouteRejection, and can
+// be added again once the server is back.
+func (st *Store) ReconcileConf(live []byte) error {
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var c CaddyConf
+	if err := json.Unmarshal(live, &c); err != nil {
+		return fmt.Errorf("unable to fit conf: %v", err)
+	}
+	if isConfEmpty(c) {
+		return fmt.Errorf("unable to reconcile conf: seems empty")
+	}
+	if c.Apps.Http.Servers[st.defaultServer] == nil {
+		return fmt.Errorf("unable to reconcile conf: server %q not found", st.defaultServer)
+	}
+	doc, err := parseDoc(live)
+	if err != nil {
+		return fmt.Errorf("unable to fit conf: %v", err)
+	}
+	assignServers(&c)
+
+	var managed []Route
+	for _, r := range st.routesNonBlocking() {
+		if st.isOwnedNonBlocking(r.Id) {
+			managed = append(managed, r)
+		}
+	}
+	for _, s := range c.Apps.Http.Servers {
+		if s == nil || s.Routes == nil {
+			continue
+		}
+		routes := []Route{}
+		for _, r := range *s.Routes {
+			if !st.isOwnedNonBlocking(r.Id) {
+				routes = append(routes, r)
+			}
+		}
+		s.Routes = &routes
+	}
+	for _, r := range managed {
+		s := c.Apps.Http.Servers[r.Server]
+		if s == nil {
+			delete(st.meta, r.Id)
+			st.dropped[r.Id] = fieldErrorf("server", "server %q not found in caddy conf", r.Server)
+			st.events.Publish(events.Event{Kind: events.RouteRemoved, RouteId: r.Id})
+			continue
+		}
+		if s.Routes == nil {
+			s.Routes = &[]Route{}
+		}
+		*s.Routes = append(*s.Routes, r)
+	}
+
+	st.conf = &c
+	st.doc = doc
+	st.arrangeNonBlocking()
+	return nil
+}
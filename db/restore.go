@@ -0,0 +1,86 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/events"
+	"reflect"
+)
+
+// RestoreConf replaces the conf along with its routes by conf, which
+// was read with ReadConf before, e.g. to roll back to it, and owned holds
+// ids of routes that were owned then, see OwnedIds.
+//
+// Routes of conf that are still owned keep their registration, other owned
+// ones are registered with reg. Owned routes missing from conf are removed.
+func (st *Store) RestoreConf(conf []byte, owned []string, reg Registration) error {
+	defer st.saveState()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var c CaddyConf
+	if err := json.Unmarshal(conf, &c); err != nil {
+		return fmt.Errorf("unable to fit conf: %v", err)
+	}
+	if isConfEmpty(c) {
+		return fmt.Errorf("unable to restore conf: seems empty")
+	}
+	if c.Apps.Http.Servers[st.defaultServer] == nil {
+		return fmt.Errorf("unable to restore conf: server %q not found", st.defaultServer)
+	}
+	doc, err := parseDoc(conf)
+	if err != nil {
+		return fmt.Errorf("unable to fit conf: %v", err)
+	}
+	assignServers(&c)
+
+	current := map[string]Route{}
+	for _, r := range st.routesNonBlocking() {
+		if r.Id != "" {
+			current[r.Id] = r
+		}
+	}
+	wasOwned := map[string]bool{}
+	for _, id := range owned {
+		wasOwned[id] = true
+	}
+	restored := map[string]bool{}
+	var taken []string
+	for _, s := range c.Apps.Http.Servers {
+		if s == nil || s.Routes == nil {
+			continue
+		}
+		for _, r := range *s.Routes {
+			if r.Id == "" {
+				continue
+			}
+			restored[r.Id] = true
+			if _, ok := st.meta[r.Id]; ok != wasOwned[r.Id] {
+				delete(st.meta, r.Id)
+				if wasOwned[r.Id] {
+					taken = append(taken, r.Id)
+				}
+			}
+			prev, ok := current[r.Id]
+			switch {
+			case !ok:
+				st.events.Publish(events.Event{Kind: events.RouteAdded, RouteId: r.Id, Route: routeToProto(r)})
+			case !reflect.DeepEqual(prev, r):
+				st.events.Publish(events.Event{Kind: events.RouteUpdated, RouteId: r.Id, Route: routeToProto(r)})
+			}
+		}
+	}
+	for id := range current {
+		if !restored[id] {
+			delete(st.meta, id)
+			st.events.Publish(events.Event{Kind: events.RouteRemoved, RouteId: id})
+		}
+	}
+
+	st.conf = &c
+	st.doc = doc
+	for _, id := range taken {
+		st.registerRouteNonBlocking(id, reg)
+	}
+	st.arrangeNonBlocking()
+	return nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"sort"
+)
+
+// SetDefaultServer sets the name of the server in Caddy's conf that routes
+// without Route.server are added to. Expected to be called before the conf
+// is set.
+func (st *Store) SetDefaultServer(name string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.defaultServer = name
+}
+
+// serverNames returns names of servers of conf in a stable order.
+func serverNames(conf *CaddyConf) []string {
+	var names []string
+	for name := range conf.Apps.Http.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// assignServers sets Route.Server of every route of conf.
+func assignServers(conf *CaddyConf) {
+	for name, s := range conf.Apps.Http.Servers {
+		if s == nil || s.Routes == nil {
+			continue
+		}
+		for i := range *s.Routes {
+			(*s.Routes)[i].Server = name
+		}
+	}
+}
+
+// routesNonBlocking returns routes of every server, ordered by server name
+// and then by their position.
+func (st *Store) routesNonBlocking() []Route {
+	var routes []Route
+	for _, name := range serverNames(st.conf) {
+		if s := st.conf.Apps.Http.Servers[name]; s != nil && s.Routes != nil {
+			routes = append(routes, *s.Routes...)
+		}
+	}
+	return routes
+}
+
+func (st *Store) hasServerNonBlocking(name string) bool {
+	return st.conf.Apps.Http.Servers[name] != nil
+}
+
+// checkServerNonBlocking returns an error if the server of r does not exist.
+func (st *Store) checkServerNonBlocking(r Route) error {
+	if !st.hasServerNonBlocking(r.Server) {
+		return fieldErrorf("server", "server %q not found in caddy conf", r.Server)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"strings"
+)
+
+// validateRoute checks every field of r that is converted into the conf and
+// returns FieldError naming the first invalid one.
+func validateRoute(r *pb.Route) error {
+	if r == nil {
+		return fieldErrorf("route", "cannot be empty")
+	}
+	if r.Id == "" {
+		return fieldErrorf("id", "cannot be empty")
+	}
+	if len(r.Handles) == 0 {
+		return fieldErrorf("handles", "should contain at least one element")
+	}
+	for i, h := range r.Handles {
+		if h == nil {
+			return fieldErrorf(fmt.Sprintf("handles[%d]", i), "cannot be empty")
+		}
+		if err := validateHandle(h); err != nil {
+			return prefixFieldError(fmt.Sprintf("handles[%d]", i), err)
+		}
+	}
+	for i, m := range r.Matches {
+		if m == nil {
+			return fieldErrorf(fmt.Sprintf("matches[%d]", i), "cannot be empty")
+		}
+		if err := validateMatch(m); err != nil {
+			return prefixFieldError(fmt.Sprintf("matches[%d]", i), err)
+		}
+	}
+	return nil
+}
+
+func validateHandle(h *pb.Handle) error {
+	switch hh := h.Handler.(type) {
+	case *pb.Handle_ReverseProxy:
+		if hh.ReverseProxy == nil {
+			return fieldErrorf("reverseProxy", "cannot be empty")
+		}
+		if err := validateReverseProxy(hh.ReverseProxy); err != nil {
+			return prefixFieldError("reverseProxy", err)
+		}
+		return nil
+	default:
+		// Also the case of handlers added to the proto after this server was built
+		return fieldErrorf("handler", "unknown or empty")
+	}
+}
+
+func validateReverseProxy(p *pb.ReverseProxy) error {
+	if p.Transport == nil {
+		return fieldErrorf("transport", "cannot be empty")
+	}
+	if _, err := transportProtocolToString(p.Transport.Protocol); err != nil {
+		return fieldErrorf("transport.protocol", "%v", err)
+	}
+	if len(p.Upstreams) == 0 {
+		return fieldErrorf("upstreams", "should contain at least one element")
+	}
+	for i, u := range p.Upstreams {
+		field := fmt.Sprintf("upstreams[%d]", i)
+		if u == nil || u.Dial == nil {
+			return fieldErrorf(field+".dial", "cannot be empty")
+		}
+		if u.Dial.Host == "" {
+			return fieldErrorf(field+".dial.host", "cannot be empty")
+		}
+		if strings.ContainsAny(u.Dial.Host, "/[] \t\r\n") {
+			return fieldErrorf(field+".dial.host", "invalid host %q", u.Dial.Host)
+		}
+		if u.Dial.Port == 0 || u.Dial.Port > 65535 {
+			return fieldErrorf(field+".dial.port", "should be between 1 and 65535, got %d", u.Dial.Port)
+		}
+	}
+	return nil
+}
+
+func validateMatch(m *pb.Match) error {
+	for i, h := range m.Hosts {
+		if h == "" || strings.ContainsAny(h, "/ \t\r\n") {
+			return fieldErrorf(fmt.Sprintf("hosts[%d]", i), "invalid host %q", h)
+		}
+	}
+	for i, p := range m.Paths {
+		if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "*") {
+			return fieldErrorf(fmt.Sprintf("paths[%d]", i), "should start with / or *, got %q", p)
+		}
+		if strings.ContainsAny(p, " \t\r\n") {
+			return fieldErrorf(fmt.Sprintf("paths[%d]", i), "cannot contain whitespace, got %q", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Kind int
+
+const (
+	// RouteAdded is published when a route with a new id enters the conf
+	RouteAdded Kind = iota
+	// RouteUpdated is published when an existing route is replaced with a different one
+	RouteUpdated
+	// RouteRemoved is published when a route leaves the conf
+	RouteRemoved
+	// RouteRejected is published when Caddy refused a route, see Message
+	RouteRejected
+	// Pushed is published when Caddy accepted a conf
+	Pushed
+	// PushFailed is published when Caddy could not be patched
+	PushFailed
+)
+
+// Event describes a single change of routes or of their delivery to Caddy.
+type Event struct {
+	Kind Kind
+	Time time.Time
+	// RouteId is empty for Pushed and PushFailed
+	RouteId string
+	// Route is set for RouteAdded and RouteUpdated
+	Route *pb.Route
+	// Message holds an error for RouteRejected and PushFailed
+	Message string
+}
+
+// Bus delivers published events to its subscribers.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subscribers: map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel receiving every published event and a function
+// to stop the subscription. A subscriber whose buffer is full is dropped and
+// its channel closed, so that it can subscribe again rather than miss events.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends e to every subscriber without blocking.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			slog.Warn("events subscriber fell behind, dropping it")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package routetest provides routes shared by tests of other packages.
+package routetest
+
+import (
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+)
+
+// Route returns a valid route with id proxying to localhost:8080.
+func Route(id string) *pb.Route {
+	return &pb.Route{
+		Id: id,
+		Handles: []*pb.Handle{
+			{Handler: &pb.Handle_ReverseProxy{ReverseProxy: &pb.ReverseProxy{
+				Transport: &pb.Transport{Protocol: pb.Transport_HTTP},
+				Upstreams: []*pb.Upstream{{Dial: &pb.Dial{Host: "localhost", Port: 8080}}},
+			}}},
+		},
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 	"log/slog"
 	"time"
 )
@@ -45,10 +48,11 @@ func SetLogger(l *slog.Logger) {
 // Logging will be sent to a slog.Default() unless changed by SetLogger.
 func Fn(dialTarget string, route *pb.Route) func(ctx context.Context) {
 	var prevAddRouteReply int32 = -1
+	prevCode := codes.OK
 	fn := func(ctx context.Context) {
 		conn, err := grpc.DialContext(ctx, dialTarget, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		if err != nil {
-			slog.Error("[caddycfginjector] did not connect", "err", err)
+			logger.Error("[caddycfginjector] did not connect", "err", err)
 			return
 		}
 
@@ -62,15 +66,81 @@ func Fn(dialTarget string, route *pb.Route) func(ctx context.Context) {
 
 		r, err := c.AddRoute(ctx, &pb.AddRouteRequest{Route: route})
 		if err != nil {
-			slog.Error("[caddycfginjector] could not add route", "err", err)
+			// Same status repeats with every call until the cause is resolved
+			if st := status.Convert(err); prevCode != st.Code() {
+				logger.Error("[caddycfginjector] could not add route", "code", st.Code(), "err", st.Message())
+				prevCode = st.Code()
+			}
+			prevAddRouteReply = -1
 			return
 		}
+		prevCode = codes.OK
 		//if r.GetResult() == pb.AddRouteReply_ok {
 		//} else if r.GetResult() == pb.AddRouteReply_error {
 		if prevAddRouteReply != int32(r.GetResult()) {
-			slog.Info("[caddycfginjector] reply", "message", r.GetMessage())
+			logger.Info("[caddycfginjector] reply", "message", r.GetMessage())
 		}
 		prevAddRouteReply = int32(r.GetResult())
 	}
 	return fn
 }
+
+// Register keeps routes registered with caddycfginjector gRPC server via dialTarget
+// over a single long-lived Register stream until ctx requests a cancellation.
+// Once the stream ends, the server removes the routes.
+//
+// Routes are re-sent over the same stream every refreshDelay, which also serves as
+// an application-level ping. Dropped connections are re-established after refreshDelay.
+//
+//	go lib.Register(ctx, "localhost:50051", time.Second*10, route)
+//
+// Logging will be sent to a slog.Default() unless changed by SetLogger.
+func Register(ctx context.Context, dialTarget string, refreshDelay time.Duration, routes ...*pb.Route) {
+	var prevReply int32 = -1
+	Periodically(ctx, refreshDelay, func(ctx context.Context) {
+		conn, err := grpc.DialContext(ctx, dialTarget,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                10 * time.Second,
+				Timeout:             5 * time.Second,
+				PermitWithoutStream: true,
+			}))
+		if err != nil {
+			logger.Error("[caddycfginjector] did not connect", "err", err)
+			return
+		}
+		defer func(conn *grpc.ClientConn) {
+			_ = conn.Close()
+		}(conn)
+
+		stream, err := pb.NewCaddyCfgInjectorClient(conn).Register(ctx)
+		if err != nil {
+			logger.Error("[caddycfginjector] could not register", "err", err)
+			return
+		}
+
+		t := time.NewTimer(0)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if err := stream.Send(&pb.RegisterRequest{Routes: routes}); err != nil {
+				logger.Error("[caddycfginjector] could not send routes", "err", err)
+				return
+			}
+			r, err := stream.Recv()
+			if err != nil {
+				logger.Error("[caddycfginjector] register stream ended", "err", err)
+				return
+			}
+			if prevReply != int32(r.GetResult()) {
+				logger.Info("[caddycfginjector] reply", "message", r.GetMessage())
+			}
+			prevReply = int32(r.GetResult())
+			t.Reset(refreshDelay)
+		}
+	})
+}
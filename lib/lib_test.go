@@ -6,7 +6,7 @@ import (
 	"strings"
 )
 
-func ExampleConfig() {
+func Example_config() {
 	s := &pb.Route{
 		Id: "example.com",
 		Handles: []*pb.Handle{
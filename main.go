@@ -4,66 +4,130 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"github.com/king8fisher/caddycfginjector/audit"
 	"github.com/king8fisher/caddycfginjector/caddy"
 	"github.com/king8fisher/caddycfginjector/db"
 	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"github.com/king8fisher/caddycfginjector/server"
 	"log/slog"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
-	//"google.golang.org/grpc/codes"
-	//"google.golang.org/grpc/status"
+	"google.golang.org/grpc/keepalive"
 	"os"
 )
 
-type server struct {
-	pb.UnimplementedCaddyCfgInjectorServer
-}
-
-func (s *server) AddRoute(_ context.Context, in *pb.AddRouteRequest) (*pb.AddRouteReply, error) {
-	err := db.AddRoute(in.Route)
-	if err != nil {
-		return &pb.AddRouteReply{
-			Result:  pb.AddRouteReply_error,
-			Message: err.Error(),
-		}, nil
-	}
-
-	cf, err := db.ReadCaddyConf()
-	if err == nil {
-		caddy.PatchCaddyCh <- cf
-		return &pb.AddRouteReply{
-			Result:  pb.AddRouteReply_ok,
-			Message: "ok",
-		}, nil
-	} else {
-		return &pb.AddRouteReply{
-			Result:  pb.AddRouteReply_error,
-			Message: err.Error(),
-		}, nil
-		// return nil, status.Errorf(codes.Unimplemented, "method AddRoute not implemented")
-	}
-}
-
 func main() {
 	var host string
 	flag.StringVar(&host, "host", "localhost", "Grpc server host. --host=\"\" to expose.")
 	var port int
 	flag.IntVar(&port, "port", 50051, "Grpc server port")
 	var caddyPort int
-	flag.IntVar(&caddyPort, "caddyPort", 2019, "Caddy port to poll and patch")
+	flag.IntVar(&caddyPort, "caddyPort", 2019, "Caddy port to poll and patch on localhost unless caddyAdmin is set")
+	var caddyAdmin string
+	flag.StringVar(&caddyAdmin, "caddyAdmin", "", "Caddy admin API address: host:port, unix/path or http(s) URL")
+	var adminOpts caddy.AdminOptions
+	flag.StringVar(&adminOpts.CertFile, "caddyCert", "", "Client certificate file for Caddy admin API mutual TLS")
+	flag.StringVar(&adminOpts.KeyFile, "caddyKey", "", "Client key file for Caddy admin API mutual TLS")
+	flag.StringVar(&adminOpts.CAFile, "caddyCA", "", "CA file verifying Caddy admin API certificate")
+	flag.StringVar(&adminOpts.Origin, "caddyOrigin", "", "Origin header sent to Caddy admin API")
+	flag.DurationVar(&adminOpts.Timeout, "caddyTimeout", 10*time.Second, "Timeout of each Caddy admin API request. 0 disables it")
+	var serverName string
+	flag.StringVar(&serverName, "server", "myserver", "Caddy HTTP server (apps.http.servers) to add routes to unless they name one")
+	var ownership db.Ownership
+	flag.StringVar(&ownership.Prefix, "ownedPrefix", "", "Route id prefix marking routes of Caddy's conf as managed by this server")
+	flag.BoolVar(&ownership.AllowOverride, "allowOverride", false, "Let clients replace or remove routes of Caddy's base conf")
+	var position string
+	flag.StringVar(&position, "position", "after", "Where managed routes are kept relative to routes of Caddy's base conf: after or before")
+	var incremental bool
+	flag.BoolVar(&incremental, "incremental", true, "Send only changed routes to Caddy through its @id endpoints instead of loading the whole conf")
+	var debounce time.Duration
+	flag.DurationVar(&debounce, "debounce", 250*time.Millisecond, "Window in which changes are merged into a single push to Caddy")
+	var baseConfig string
+	flag.StringVar(&baseConfig, "baseConfig", "", "JSON file with the initial conf sent to Caddy, ${VAR} placeholders are expanded from baseValues or environment")
+	var baseValues string
+	flag.StringVar(&baseValues, "baseValues", "", "File with NAME=value lines expanding placeholders of baseConfig before environment")
+	var stateDir string
+	flag.StringVar(&stateDir, "stateDir", "", "Directory where added routes are saved to be restored after a restart. Empty disables it")
 	var init bool
 	flag.BoolVar(&init, "init", true, "Attempt to send initial conf to Caddy if returns empty")
+	var reconcile time.Duration
+	flag.DurationVar(&reconcile, "reconcile", 10*time.Second, "Interval of comparing Caddy's conf with the desired one to restore routes after Caddy restarts. 0 disables it")
+	var legacyReplies bool
+	flag.BoolVar(&legacyReplies, "legacyReplies", false, "Report errors in replies with result=error instead of gRPC status codes")
+	var wait bool
+	flag.BoolVar(&wait, "wait", false, "Wait until Caddy applies added routes before replying, unless the request sets wait")
+	var waitTimeout time.Duration
+	flag.DurationVar(&waitTimeout, "waitTimeout", server.DefaultWaitTimeout, "Longest time to wait for Caddy to apply added routes")
+	var ttl time.Duration
+	flag.DurationVar(&ttl, "ttl", 0, "Default route lease unless set by the client. Routes not re-added within it are removed. 0 disables expiry")
+
+	var auditLog string
+	flag.StringVar(&auditLog, "auditLog", "", "File where every change of routes is appended as a JSON line. Empty disables it")
+	var auditMaxSize int64
+	flag.Int64Var(&auditMaxSize, "auditMaxSize", 10<<20, "Size in bytes at which auditLog is rotated")
+	var auditKeep int
+	flag.IntVar(&auditKeep, "auditKeep", 5, "Number of rotated auditLog files kept")
+
+	var history int
+	flag.IntVar(&history, "history", 20, "Number of confs accepted by Caddy kept for rollback")
 
 	help := false
 	flag.BoolVar(&help, "h", false, "Show help")
 	flag.BoolVar(&help, "help", false, "Show help")
 
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %v [flags] [command]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), commandsUsage)
+	}
 	flag.Parse()
 	if help {
 		flag.Usage()
 		os.Exit(2)
 	}
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(fmt.Sprintf("%v:%d", host, port), flag.Args()))
+	}
+
+	store := db.NewStore()
+	client := caddy.NewClient(store)
+	store.SetDefaultServer(serverName)
+	client.SetHistorySize(history)
+	switch position {
+	case "after":
+	case "before":
+		ownership.First = true
+	default:
+		slog.Error("invalid position, expected after or before", "position", position)
+		os.Exit(2)
+	}
+	store.SetOwnership(ownership)
+	if baseConfig != "" {
+		conf, err := db.LoadBaseConf(baseConfig, baseValues)
+		if err == nil {
+			err = store.SetInitialConf(conf)
+		}
+		if err != nil {
+			slog.Error("invalid base config", "err", err)
+			os.Exit(2)
+		}
+	}
+	if caddyAdmin == "" {
+		caddyAdmin = fmt.Sprintf("localhost:%d", caddyPort)
+	}
+	if err := client.SetAdmin(caddyAdmin, adminOpts); err != nil {
+		slog.Error("invalid caddy admin", "err", err)
+		os.Exit(2)
+	}
+
+	if stateDir != "" {
+		if err := store.SetStateDir(stateDir); err != nil {
+			slog.Error("unable to restore routes", "dir", stateDir, "err", err)
+			os.Exit(1)
+		}
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf("%v:%d", host, port))
 	if err != nil {
@@ -71,11 +135,36 @@ func main() {
 		os.Exit(1)
 	}
 
-	go caddy.PollCaddy(context.Background(), caddyPort, init)
-	go caddy.PatchCaddy(context.Background(), caddyPort)
+	opts := server.Options{
+		DefaultTTL:    ttl,
+		LegacyReplies: legacyReplies,
+		Wait:          wait,
+		WaitTimeout:   waitTimeout,
+	}
+	if auditLog != "" {
+		if opts.Audit, err = audit.Open(auditLog, auditMaxSize, auditKeep); err != nil {
+			slog.Error("unable to open audit log", "err", err)
+			os.Exit(1)
+		}
+	}
+	srv := server.New(store, client, opts)
+
+	go client.Poll(context.Background(), init, reconcile)
+	go client.Patch(context.Background(), incremental, debounce)
+	go srv.SweepExpiredRoutes(context.Background(), time.Second)
 
-	s := grpc.NewServer()
-	pb.RegisterCaddyCfgInjectorServer(s, &server{})
+	s := grpc.NewServer(
+		// Dead Register streams are detected by keepalives and their routes removed
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    10 * time.Second,
+			Timeout: 5 * time.Second,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             5 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	pb.RegisterCaddyCfgInjectorServer(s, srv)
 	slog.Info("caddycfginjector listens", "addr", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		slog.Error("failed to serve", "err", err)
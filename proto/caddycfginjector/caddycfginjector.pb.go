@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.28.1
+// 	protoc-gen-go v1.31.0
 // 	protoc        v4.24.2
 // source: caddycfginjector.proto
 
@@ -9,6 +9,7 @@ package caddycfginjector
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 )
@@ -112,12 +113,271 @@ func (AddRouteReply_ReplyResult) EnumDescriptor() ([]byte, []int) {
 	return file_caddycfginjector_proto_rawDescGZIP(), []int{8, 0}
 }
 
+type AddRoutesReply_ReplyResult int32
+
+const (
+	AddRoutesReply_ok    AddRoutesReply_ReplyResult = 0
+	AddRoutesReply_error AddRoutesReply_ReplyResult = 1
+)
+
+// Enum value maps for AddRoutesReply_ReplyResult.
+var (
+	AddRoutesReply_ReplyResult_name = map[int32]string{
+		0: "ok",
+		1: "error",
+	}
+	AddRoutesReply_ReplyResult_value = map[string]int32{
+		"ok":    0,
+		"error": 1,
+	}
+)
+
+func (x AddRoutesReply_ReplyResult) Enum() *AddRoutesReply_ReplyResult {
+	p := new(AddRoutesReply_ReplyResult)
+	*p = x
+	return p
+}
+
+func (x AddRoutesReply_ReplyResult) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AddRoutesReply_ReplyResult) Descriptor() protoreflect.EnumDescriptor {
+	return file_caddycfginjector_proto_enumTypes[2].Descriptor()
+}
+
+func (AddRoutesReply_ReplyResult) Type() protoreflect.EnumType {
+	return &file_caddycfginjector_proto_enumTypes[2]
+}
+
+func (x AddRoutesReply_ReplyResult) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AddRoutesReply_ReplyResult.Descriptor instead.
+func (AddRoutesReply_ReplyResult) EnumDescriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{10, 0}
+}
+
+type RemoveRouteReply_ReplyResult int32
+
+const (
+	RemoveRouteReply_ok    RemoveRouteReply_ReplyResult = 0
+	RemoveRouteReply_error RemoveRouteReply_ReplyResult = 1
+)
+
+// Enum value maps for RemoveRouteReply_ReplyResult.
+var (
+	RemoveRouteReply_ReplyResult_name = map[int32]string{
+		0: "ok",
+		1: "error",
+	}
+	RemoveRouteReply_ReplyResult_value = map[string]int32{
+		"ok":    0,
+		"error": 1,
+	}
+)
+
+func (x RemoveRouteReply_ReplyResult) Enum() *RemoveRouteReply_ReplyResult {
+	p := new(RemoveRouteReply_ReplyResult)
+	*p = x
+	return p
+}
+
+func (x RemoveRouteReply_ReplyResult) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RemoveRouteReply_ReplyResult) Descriptor() protoreflect.EnumDescriptor {
+	return file_caddycfginjector_proto_enumTypes[3].Descriptor()
+}
+
+func (RemoveRouteReply_ReplyResult) Type() protoreflect.EnumType {
+	return &file_caddycfginjector_proto_enumTypes[3]
+}
+
+func (x RemoveRouteReply_ReplyResult) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RemoveRouteReply_ReplyResult.Descriptor instead.
+func (RemoveRouteReply_ReplyResult) EnumDescriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{12, 0}
+}
+
+type RegisterReply_ReplyResult int32
+
+const (
+	RegisterReply_ok    RegisterReply_ReplyResult = 0
+	RegisterReply_error RegisterReply_ReplyResult = 1
+)
+
+// Enum value maps for RegisterReply_ReplyResult.
+var (
+	RegisterReply_ReplyResult_name = map[int32]string{
+		0: "ok",
+		1: "error",
+	}
+	RegisterReply_ReplyResult_value = map[string]int32{
+		"ok":    0,
+		"error": 1,
+	}
+)
+
+func (x RegisterReply_ReplyResult) Enum() *RegisterReply_ReplyResult {
+	p := new(RegisterReply_ReplyResult)
+	*p = x
+	return p
+}
+
+func (x RegisterReply_ReplyResult) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RegisterReply_ReplyResult) Descriptor() protoreflect.EnumDescriptor {
+	return file_caddycfginjector_proto_enumTypes[4].Descriptor()
+}
+
+func (RegisterReply_ReplyResult) Type() protoreflect.EnumType {
+	return &file_caddycfginjector_proto_enumTypes[4]
+}
+
+func (x RegisterReply_ReplyResult) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RegisterReply_ReplyResult.Descriptor instead.
+func (RegisterReply_ReplyResult) EnumDescriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{14, 0}
+}
+
+type RouteEvent_Kind int32
+
+const (
+	// Route existing when watching started
+	RouteEvent_snapshot RouteEvent_Kind = 0
+	// All snapshot events were sent, incremental events follow
+	RouteEvent_snapshotDone RouteEvent_Kind = 1
+	RouteEvent_added        RouteEvent_Kind = 2
+	RouteEvent_updated      RouteEvent_Kind = 3
+	RouteEvent_removed      RouteEvent_Kind = 4
+	// Caddy accepted the conf
+	RouteEvent_pushed RouteEvent_Kind = 5
+	// Caddy could not be patched, see message
+	RouteEvent_pushFailed RouteEvent_Kind = 6
+	// Caddy rejected the route, see message
+	RouteEvent_rejected RouteEvent_Kind = 7
+)
+
+// Enum value maps for RouteEvent_Kind.
+var (
+	RouteEvent_Kind_name = map[int32]string{
+		0: "snapshot",
+		1: "snapshotDone",
+		2: "added",
+		3: "updated",
+		4: "removed",
+		5: "pushed",
+		6: "pushFailed",
+		7: "rejected",
+	}
+	RouteEvent_Kind_value = map[string]int32{
+		"snapshot":     0,
+		"snapshotDone": 1,
+		"added":        2,
+		"updated":      3,
+		"removed":      4,
+		"pushed":       5,
+		"pushFailed":   6,
+		"rejected":     7,
+	}
+)
+
+func (x RouteEvent_Kind) Enum() *RouteEvent_Kind {
+	p := new(RouteEvent_Kind)
+	*p = x
+	return p
+}
+
+func (x RouteEvent_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RouteEvent_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_caddycfginjector_proto_enumTypes[5].Descriptor()
+}
+
+func (RouteEvent_Kind) Type() protoreflect.EnumType {
+	return &file_caddycfginjector_proto_enumTypes[5]
+}
+
+func (x RouteEvent_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RouteEvent_Kind.Descriptor instead.
+func (RouteEvent_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{21, 0}
+}
+
+type RollbackReply_ReplyResult int32
+
+const (
+	RollbackReply_ok    RollbackReply_ReplyResult = 0
+	RollbackReply_error RollbackReply_ReplyResult = 1
+)
+
+// Enum value maps for RollbackReply_ReplyResult.
+var (
+	RollbackReply_ReplyResult_name = map[int32]string{
+		0: "ok",
+		1: "error",
+	}
+	RollbackReply_ReplyResult_value = map[string]int32{
+		"ok":    0,
+		"error": 1,
+	}
+)
+
+func (x RollbackReply_ReplyResult) Enum() *RollbackReply_ReplyResult {
+	p := new(RollbackReply_ReplyResult)
+	*p = x
+	return p
+}
+
+func (x RollbackReply_ReplyResult) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RollbackReply_ReplyResult) Descriptor() protoreflect.EnumDescriptor {
+	return file_caddycfginjector_proto_enumTypes[6].Descriptor()
+}
+
+func (RollbackReply_ReplyResult) Type() protoreflect.EnumType {
+	return &file_caddycfginjector_proto_enumTypes[6]
+}
+
+func (x RollbackReply_ReplyResult) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RollbackReply_ReplyResult.Descriptor instead.
+func (RollbackReply_ReplyResult) EnumDescriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{28, 0}
+}
+
 type AddRouteRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	Route *Route `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	// Lease of the route in seconds, refreshed with every AddRoute.
+	// The route is removed once the lease expires. 0 uses server default.
+	TtlSeconds uint32 `protobuf:"varint,2,opt,name=ttlSeconds,proto3" json:"ttlSeconds,omitempty"`
+	// Wait until Caddy has applied the route and reply with its verdict.
+	// Unset uses server default.
+	Wait *bool `protobuf:"varint,3,opt,name=wait,proto3,oneof" json:"wait,omitempty"`
 }
 
 func (x *AddRouteRequest) Reset() {
@@ -159,6 +419,20 @@ func (x *AddRouteRequest) GetRoute() *Route {
 	return nil
 }
 
+func (x *AddRouteRequest) GetTtlSeconds() uint32 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *AddRouteRequest) GetWait() bool {
+	if x != nil && x.Wait != nil {
+		return *x.Wait
+	}
+	return false
+}
+
 type Route struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -167,6 +441,9 @@ type Route struct {
 	Id      string    `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Handles []*Handle `protobuf:"bytes,2,rep,name=handles,proto3" json:"handles,omitempty"`
 	Matches []*Match  `protobuf:"bytes,3,rep,name=matches,proto3" json:"matches,omitempty"`
+	// Name of the Caddy HTTP server (apps.http.servers) the route belongs to.
+	// Empty uses server default (--server).
+	Server string `protobuf:"bytes,4,opt,name=server,proto3" json:"server,omitempty"`
 }
 
 func (x *Route) Reset() {
@@ -222,13 +499,19 @@ func (x *Route) GetMatches() []*Match {
 	return nil
 }
 
+func (x *Route) GetServer() string {
+	if x != nil {
+		return x.Server
+	}
+	return ""
+}
+
 type Handle struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	// Types that are assignable to Handler:
-	//
 	//	*Handle_ReverseProxy
 	Handler isHandle_Handler `protobuf_oneof:"handler"`
 }
@@ -606,154 +889,1490 @@ func (x *AddRouteReply) GetMessage() string {
 	return ""
 }
 
-var File_caddycfginjector_proto protoreflect.FileDescriptor
+type AddRoutesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-var file_caddycfginjector_proto_rawDesc = []byte{
-	0x0a, 0x16, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
-	0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63,
-	0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x22, 0x40, 0x0a, 0x0f, 0x41, 0x64,
-	0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2d, 0x0a,
-	0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63,
-	0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e,
-	0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x22, 0x7e, 0x0a, 0x05,
-	0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x32, 0x0a, 0x07, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x73,
-	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66,
-	0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65,
-	0x52, 0x07, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x73, 0x12, 0x31, 0x0a, 0x07, 0x6d, 0x61, 0x74,
-	0x63, 0x68, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x64,
-	0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61,
-	0x74, 0x63, 0x68, 0x52, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x22, 0x59, 0x0a, 0x06,
-	0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x44, 0x0a, 0x0c, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73,
-	0x65, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63,
-	0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e,
-	0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x48, 0x00, 0x52, 0x0c,
-	0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x42, 0x09, 0x0a, 0x07,
-	0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x72, 0x22, 0x83, 0x01, 0x0a, 0x0c, 0x52, 0x65, 0x76, 0x65,
-	0x72, 0x73, 0x65, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x12, 0x39, 0x0a, 0x09, 0x74, 0x72, 0x61, 0x6e,
-	0x73, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61,
-	0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x54,
-	0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x09, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70,
-	0x6f, 0x72, 0x74, 0x12, 0x38, 0x0a, 0x09, 0x75, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73,
-	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66,
-	0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x55, 0x70, 0x73, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x52, 0x09, 0x75, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x22, 0x70, 0x0a,
-	0x09, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x40, 0x0a, 0x08, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x24, 0x2e, 0x63,
-	0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e,
-	0x54, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63,
-	0x6f, 0x6c, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x22, 0x21, 0x0a, 0x08,
-	0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x08, 0x0a, 0x04, 0x48, 0x54, 0x54, 0x50,
-	0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x46, 0x61, 0x73, 0x74, 0x43, 0x47, 0x49, 0x10, 0x01, 0x22,
-	0x36, 0x0a, 0x08, 0x55, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2a, 0x0a, 0x04, 0x64,
-	0x69, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x64, 0x64,
-	0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x44, 0x69, 0x61,
-	0x6c, 0x52, 0x04, 0x64, 0x69, 0x61, 0x6c, 0x22, 0x2e, 0x0a, 0x04, 0x44, 0x69, 0x61, 0x6c, 0x12,
-	0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68,
-	0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x22, 0x33, 0x0a, 0x05, 0x4d, 0x61, 0x74, 0x63, 0x68,
-	0x12, 0x14, 0x0a, 0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52,
-	0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x61, 0x74, 0x68, 0x73, 0x18,
-	0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x70, 0x61, 0x74, 0x68, 0x73, 0x22, 0x90, 0x01, 0x0a,
-	0x0d, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x43,
-	0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b,
-	0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f,
-	0x72, 0x2e, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e,
-	0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73,
-	0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x20, 0x0a,
-	0x0b, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x06, 0x0a, 0x02,
-	0x6f, 0x6b, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x01, 0x32,
-	0x64, 0x0a, 0x10, 0x43, 0x61, 0x64, 0x64, 0x79, 0x43, 0x66, 0x67, 0x49, 0x6e, 0x6a, 0x65, 0x63,
-	0x74, 0x6f, 0x72, 0x12, 0x50, 0x0a, 0x08, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x12,
-	0x21, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
-	0x6f, 0x72, 0x2e, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a,
-	0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65,
-	0x70, 0x6c, 0x79, 0x22, 0x00, 0x42, 0x40, 0x5a, 0x3e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
-	0x63, 0x6f, 0x6d, 0x2f, 0x6b, 0x69, 0x6e, 0x67, 0x38, 0x66, 0x69, 0x73, 0x68, 0x65, 0x72, 0x2f,
-	0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72,
-	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69,
-	0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	Routes []*Route `protobuf:"bytes,1,rep,name=routes,proto3" json:"routes,omitempty"`
+	// Lease of every route in seconds, see AddRouteRequest.ttlSeconds
+	TtlSeconds uint32 `protobuf:"varint,2,opt,name=ttlSeconds,proto3" json:"ttlSeconds,omitempty"`
+	// See AddRouteRequest.wait
+	Wait *bool `protobuf:"varint,3,opt,name=wait,proto3,oneof" json:"wait,omitempty"`
 }
 
-var (
-	file_caddycfginjector_proto_rawDescOnce sync.Once
-	file_caddycfginjector_proto_rawDescData = file_caddycfginjector_proto_rawDesc
-)
+func (x *AddRoutesRequest) Reset() {
+	*x = AddRoutesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_caddycfginjector_proto_rawDescGZIP() []byte {
-	file_caddycfginjector_proto_rawDescOnce.Do(func() {
-		file_caddycfginjector_proto_rawDescData = protoimpl.X.CompressGZIP(file_caddycfginjector_proto_rawDescData)
-	})
-	return file_caddycfginjector_proto_rawDescData
+func (x *AddRoutesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_caddycfginjector_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_caddycfginjector_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
-var file_caddycfginjector_proto_goTypes = []interface{}{
-	(Transport_Protocol)(0),        // 0: caddycfginjector.Transport.Protocol
-	(AddRouteReply_ReplyResult)(0), // 1: caddycfginjector.AddRouteReply.ReplyResult
-	(*AddRouteRequest)(nil),        // 2: caddycfginjector.AddRouteRequest
-	(*Route)(nil),                  // 3: caddycfginjector.Route
-	(*Handle)(nil),                 // 4: caddycfginjector.Handle
-	(*ReverseProxy)(nil),           // 5: caddycfginjector.ReverseProxy
-	(*Transport)(nil),              // 6: caddycfginjector.Transport
-	(*Upstream)(nil),               // 7: caddycfginjector.Upstream
-	(*Dial)(nil),                   // 8: caddycfginjector.Dial
-	(*Match)(nil),                  // 9: caddycfginjector.Match
-	(*AddRouteReply)(nil),          // 10: caddycfginjector.AddRouteReply
+func (*AddRoutesRequest) ProtoMessage() {}
+
+func (x *AddRoutesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_caddycfginjector_proto_depIdxs = []int32{
-	3,  // 0: caddycfginjector.AddRouteRequest.route:type_name -> caddycfginjector.Route
-	4,  // 1: caddycfginjector.Route.handles:type_name -> caddycfginjector.Handle
-	9,  // 2: caddycfginjector.Route.matches:type_name -> caddycfginjector.Match
-	5,  // 3: caddycfginjector.Handle.reverseProxy:type_name -> caddycfginjector.ReverseProxy
-	6,  // 4: caddycfginjector.ReverseProxy.transport:type_name -> caddycfginjector.Transport
-	7,  // 5: caddycfginjector.ReverseProxy.upstreams:type_name -> caddycfginjector.Upstream
-	0,  // 6: caddycfginjector.Transport.protocol:type_name -> caddycfginjector.Transport.Protocol
-	8,  // 7: caddycfginjector.Upstream.dial:type_name -> caddycfginjector.Dial
-	1,  // 8: caddycfginjector.AddRouteReply.result:type_name -> caddycfginjector.AddRouteReply.ReplyResult
-	2,  // 9: caddycfginjector.CaddyCfgInjector.AddRoute:input_type -> caddycfginjector.AddRouteRequest
-	10, // 10: caddycfginjector.CaddyCfgInjector.AddRoute:output_type -> caddycfginjector.AddRouteReply
-	10, // [10:11] is the sub-list for method output_type
-	9,  // [9:10] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+
+// Deprecated: Use AddRoutesRequest.ProtoReflect.Descriptor instead.
+func (*AddRoutesRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{9}
 }
 
-func init() { file_caddycfginjector_proto_init() }
-func file_caddycfginjector_proto_init() {
-	if File_caddycfginjector_proto != nil {
-		return
+func (x *AddRoutesRequest) GetRoutes() []*Route {
+	if x != nil {
+		return x.Routes
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_caddycfginjector_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*AddRouteRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_caddycfginjector_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Route); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+	return nil
+}
+
+func (x *AddRoutesRequest) GetTtlSeconds() uint32 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+func (x *AddRoutesRequest) GetWait() bool {
+	if x != nil && x.Wait != nil {
+		return *x.Wait
+	}
+	return false
+}
+
+type AddRoutesReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result  AddRoutesReply_ReplyResult `protobuf:"varint,1,opt,name=result,proto3,enum=caddycfginjector.AddRoutesReply_ReplyResult" json:"result,omitempty"`
+	Message string                     `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *AddRoutesReply) Reset() {
+	*x = AddRoutesReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddRoutesReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRoutesReply) ProtoMessage() {}
+
+func (x *AddRoutesReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_caddycfginjector_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRoutesReply.ProtoReflect.Descriptor instead.
+func (*AddRoutesReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AddRoutesReply) GetResult() AddRoutesReply_ReplyResult {
+	if x != nil {
+		return x.Result
+	}
+	return AddRoutesReply_ok
+}
+
+func (x *AddRoutesReply) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type RemoveRouteRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Route.id used when the route was added
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *RemoveRouteRequest) Reset() {
+	*x = RemoveRouteRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveRouteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveRouteRequest) ProtoMessage() {}
+
+func (x *RemoveRouteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveRouteRequest.ProtoReflect.Descriptor instead.
+func (*RemoveRouteRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RemoveRouteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type RemoveRouteReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result  RemoveRouteReply_ReplyResult `protobuf:"varint,1,opt,name=result,proto3,enum=caddycfginjector.RemoveRouteReply_ReplyResult" json:"result,omitempty"`
+	Message string                       `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Whether a route with the requested id was registered
+	Existed bool `protobuf:"varint,3,opt,name=existed,proto3" json:"existed,omitempty"`
+}
+
+func (x *RemoveRouteReply) Reset() {
+	*x = RemoveRouteReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveRouteReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveRouteReply) ProtoMessage() {}
+
+func (x *RemoveRouteReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveRouteReply.ProtoReflect.Descriptor instead.
+func (*RemoveRouteReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RemoveRouteReply) GetResult() RemoveRouteReply_ReplyResult {
+	if x != nil {
+		return x.Result
+	}
+	return RemoveRouteReply_ok
+}
+
+func (x *RemoveRouteReply) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RemoveRouteReply) GetExisted() bool {
+	if x != nil {
+		return x.Existed
+	}
+	return false
+}
+
+type RegisterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Routes to add or refresh. A message without routes acts as a ping.
+	Routes []*Route `protobuf:"bytes,1,rep,name=routes,proto3" json:"routes,omitempty"`
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RegisterRequest) GetRoutes() []*Route {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+type RegisterReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result  RegisterReply_ReplyResult `protobuf:"varint,1,opt,name=result,proto3,enum=caddycfginjector.RegisterReply_ReplyResult" json:"result,omitempty"`
+	Message string                    `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *RegisterReply) Reset() {
+	*x = RegisterReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegisterReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterReply) ProtoMessage() {}
+
+func (x *RegisterReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterReply.ProtoReflect.Descriptor instead.
+func (*RegisterReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RegisterReply) GetResult() RegisterReply_ReplyResult {
+	if x != nil {
+		return x.Result
+	}
+	return RegisterReply_ok
+}
+
+func (x *RegisterReply) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type RouteInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Route *Route `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	// Time the route was last added. Empty for routes of the base conf.
+	Registered *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=registered,proto3" json:"registered,omitempty"`
+	// Address of the client that last added the route
+	Peer string `protobuf:"bytes,3,opt,name=peer,proto3" json:"peer,omitempty"`
+	// Time the lease of the route ends. Empty for routes without a lease.
+	Expires *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires,proto3" json:"expires,omitempty"`
+}
+
+func (x *RouteInfo) Reset() {
+	*x = RouteInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RouteInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteInfo) ProtoMessage() {}
+
+func (x *RouteInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteInfo.ProtoReflect.Descriptor instead.
+func (*RouteInfo) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RouteInfo) GetRoute() *Route {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+func (x *RouteInfo) GetRegistered() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Registered
+	}
+	return nil
+}
+
+func (x *RouteInfo) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *RouteInfo) GetExpires() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Expires
+	}
+	return nil
+}
+
+type ListRoutesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Only list routes whose id starts with idPrefix, if set
+	IdPrefix string `protobuf:"bytes,1,opt,name=idPrefix,proto3" json:"idPrefix,omitempty"`
+	// Only list routes matching host, if set
+	Host string `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *ListRoutesRequest) Reset() {
+	*x = ListRoutesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListRoutesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoutesRequest) ProtoMessage() {}
+
+func (x *ListRoutesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoutesRequest.ProtoReflect.Descriptor instead.
+func (*ListRoutesRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListRoutesRequest) GetIdPrefix() string {
+	if x != nil {
+		return x.IdPrefix
+	}
+	return ""
+}
+
+func (x *ListRoutesRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type ListRoutesReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Routes []*RouteInfo `protobuf:"bytes,1,rep,name=routes,proto3" json:"routes,omitempty"`
+}
+
+func (x *ListRoutesReply) Reset() {
+	*x = ListRoutesReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListRoutesReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRoutesReply) ProtoMessage() {}
+
+func (x *ListRoutesReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRoutesReply.ProtoReflect.Descriptor instead.
+func (*ListRoutesReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ListRoutesReply) GetRoutes() []*RouteInfo {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+type GetRouteRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *GetRouteRequest) Reset() {
+	*x = GetRouteRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetRouteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRouteRequest) ProtoMessage() {}
+
+func (x *GetRouteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRouteRequest.ProtoReflect.Descriptor instead.
+func (*GetRouteRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GetRouteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetRouteReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Route *RouteInfo `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+}
+
+func (x *GetRouteReply) Reset() {
+	*x = GetRouteReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetRouteReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRouteReply) ProtoMessage() {}
+
+func (x *GetRouteReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRouteReply.ProtoReflect.Descriptor instead.
+func (*GetRouteReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetRouteReply) GetRoute() *RouteInfo {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+type WatchRoutesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *WatchRoutesRequest) Reset() {
+	*x = WatchRoutesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchRoutesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRoutesRequest) ProtoMessage() {}
+
+func (x *WatchRoutesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRoutesRequest.ProtoReflect.Descriptor instead.
+func (*WatchRoutesRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{20}
+}
+
+type RouteEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Kind RouteEvent_Kind        `protobuf:"varint,1,opt,name=kind,proto3,enum=caddycfginjector.RouteEvent_Kind" json:"kind,omitempty"`
+	Time *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	// Route id, empty for pushed and pushFailed
+	Id string `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	// Set for snapshot, added and updated
+	Route   *RouteInfo `protobuf:"bytes,4,opt,name=route,proto3" json:"route,omitempty"`
+	Message string     `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *RouteEvent) Reset() {
+	*x = RouteEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RouteEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteEvent) ProtoMessage() {}
+
+func (x *RouteEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteEvent.ProtoReflect.Descriptor instead.
+func (*RouteEvent) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *RouteEvent) GetKind() RouteEvent_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return RouteEvent_snapshot
+}
+
+func (x *RouteEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *RouteEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RouteEvent) GetRoute() *RouteInfo {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+func (x *RouteEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ListVersionsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListVersionsRequest) Reset() {
+	*x = ListVersionsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListVersionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVersionsRequest) ProtoMessage() {}
+
+func (x *ListVersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVersionsRequest.ProtoReflect.Descriptor instead.
+func (*ListVersionsRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{22}
+}
+
+type Version struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Number of the version, increasing with every conf Caddy accepted
+	Number uint64                 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Pushed *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=pushed,proto3" json:"pushed,omitempty"`
+	// Ids of routes of the conf
+	RouteIds []string `protobuf:"bytes,3,rep,name=routeIds,proto3" json:"routeIds,omitempty"`
+}
+
+func (x *Version) Reset() {
+	*x = Version{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Version) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Version) ProtoMessage() {}
+
+func (x *Version) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Version.ProtoReflect.Descriptor instead.
+func (*Version) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Version) GetNumber() uint64 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *Version) GetPushed() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Pushed
+	}
+	return nil
+}
+
+func (x *Version) GetRouteIds() []string {
+	if x != nil {
+		return x.RouteIds
+	}
+	return nil
+}
+
+type ListVersionsReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Versions []*Version `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
+}
+
+func (x *ListVersionsReply) Reset() {
+	*x = ListVersionsReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListVersionsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVersionsReply) ProtoMessage() {}
+
+func (x *ListVersionsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVersionsReply.ProtoReflect.Descriptor instead.
+func (*ListVersionsReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ListVersionsReply) GetVersions() []*Version {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+type DiffVersionsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	From uint64 `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	// 0 diffs against the latest version
+	To uint64 `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+}
+
+func (x *DiffVersionsRequest) Reset() {
+	*x = DiffVersionsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DiffVersionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffVersionsRequest) ProtoMessage() {}
+
+func (x *DiffVersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffVersionsRequest.ProtoReflect.Descriptor instead.
+func (*DiffVersionsRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *DiffVersionsRequest) GetFrom() uint64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *DiffVersionsRequest) GetTo() uint64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type DiffVersionsReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Unified diff of the confs
+	Diff string `protobuf:"bytes,1,opt,name=diff,proto3" json:"diff,omitempty"`
+}
+
+func (x *DiffVersionsReply) Reset() {
+	*x = DiffVersionsReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DiffVersionsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffVersionsReply) ProtoMessage() {}
+
+func (x *DiffVersionsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffVersionsReply.ProtoReflect.Descriptor instead.
+func (*DiffVersionsReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DiffVersionsReply) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+type RollbackRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version uint64 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (x *RollbackRequest) Reset() {
+	*x = RollbackRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RollbackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackRequest) ProtoMessage() {}
+
+func (x *RollbackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackRequest.ProtoReflect.Descriptor instead.
+func (*RollbackRequest) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *RollbackRequest) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type RollbackReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result  RollbackReply_ReplyResult `protobuf:"varint,1,opt,name=result,proto3,enum=caddycfginjector.RollbackReply_ReplyResult" json:"result,omitempty"`
+	Message string                    `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Number of the version created by the rollback
+	Version uint64 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (x *RollbackReply) Reset() {
+	*x = RollbackReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_caddycfginjector_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RollbackReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackReply) ProtoMessage() {}
+
+func (x *RollbackReply) ProtoReflect() protoreflect.Message {
+	mi := &file_caddycfginjector_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackReply.ProtoReflect.Descriptor instead.
+func (*RollbackReply) Descriptor() ([]byte, []int) {
+	return file_caddycfginjector_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *RollbackReply) GetResult() RollbackReply_ReplyResult {
+	if x != nil {
+		return x.Result
+	}
+	return RollbackReply_ok
+}
+
+func (x *RollbackReply) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RollbackReply) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+var File_caddycfginjector_proto protoreflect.FileDescriptor
+
+var file_caddycfginjector_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63,
+	0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x82, 0x01, 0x0a, 0x0f,
+	0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x2d, 0x0a, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f,
+	0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x1e,
+	0x0a, 0x0a, 0x74, 0x74, 0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0a, 0x74, 0x74, 0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x17,
+	0x0a, 0x04, 0x77, 0x61, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x04,
+	0x77, 0x61, 0x69, 0x74, 0x88, 0x01, 0x01, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x77, 0x61, 0x69, 0x74,
+	0x22, 0x96, 0x01, 0x0a, 0x05, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x32, 0x0a, 0x07, 0x68, 0x61,
+	0x6e, 0x64, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x61,
+	0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x48,
+	0x61, 0x6e, 0x64, 0x6c, 0x65, 0x52, 0x07, 0x68, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x73, 0x12, 0x31,
+	0x0a, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x17, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65,
+	0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x22, 0x59, 0x0a, 0x06, 0x48, 0x61, 0x6e,
+	0x64, 0x6c, 0x65, 0x12, 0x44, 0x0a, 0x0c, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x50, 0x72,
+	0x6f, 0x78, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x61, 0x64, 0x64,
+	0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x76,
+	0x65, 0x72, 0x73, 0x65, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x48, 0x00, 0x52, 0x0c, 0x72, 0x65, 0x76,
+	0x65, 0x72, 0x73, 0x65, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x42, 0x09, 0x0a, 0x07, 0x68, 0x61, 0x6e,
+	0x64, 0x6c, 0x65, 0x72, 0x22, 0x83, 0x01, 0x0a, 0x0c, 0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65,
+	0x50, 0x72, 0x6f, 0x78, 0x79, 0x12, 0x39, 0x0a, 0x09, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f,
+	0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79,
+	0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x54, 0x72, 0x61, 0x6e,
+	0x73, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x09, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74,
+	0x12, 0x38, 0x0a, 0x09, 0x75, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e,
+	0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x55, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52,
+	0x09, 0x75, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x22, 0x70, 0x0a, 0x09, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x40, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x24, 0x2e, 0x63, 0x61, 0x64, 0x64,
+	0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x54, 0x72, 0x61,
+	0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x52,
+	0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x22, 0x21, 0x0a, 0x08, 0x50, 0x72, 0x6f,
+	0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x08, 0x0a, 0x04, 0x48, 0x54, 0x54, 0x50, 0x10, 0x00, 0x12,
+	0x0b, 0x0a, 0x07, 0x46, 0x61, 0x73, 0x74, 0x43, 0x47, 0x49, 0x10, 0x01, 0x22, 0x36, 0x0a, 0x08,
+	0x55, 0x70, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2a, 0x0a, 0x04, 0x64, 0x69, 0x61, 0x6c,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66,
+	0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x44, 0x69, 0x61, 0x6c, 0x52, 0x04,
+	0x64, 0x69, 0x61, 0x6c, 0x22, 0x2e, 0x0a, 0x04, 0x44, 0x69, 0x61, 0x6c, 0x12, 0x12, 0x0a, 0x04,
+	0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04,
+	0x70, 0x6f, 0x72, 0x74, 0x22, 0x33, 0x0a, 0x05, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x14, 0x0a,
+	0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x68, 0x6f,
+	0x73, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x61, 0x74, 0x68, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x05, 0x70, 0x61, 0x74, 0x68, 0x73, 0x22, 0x90, 0x01, 0x0a, 0x0d, 0x41, 0x64,
+	0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x43, 0x0a, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63, 0x61,
+	0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x41,
+	0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e, 0x52, 0x65, 0x70,
+	0x6c, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x20, 0x0a, 0x0b, 0x52, 0x65,
+	0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x06, 0x0a, 0x02, 0x6f, 0x6b, 0x10,
+	0x00, 0x12, 0x09, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x01, 0x22, 0x85, 0x01, 0x0a,
+	0x10, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x2f, 0x0a, 0x06, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65,
+	0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x06, 0x72, 0x6f, 0x75, 0x74,
+	0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x74, 0x74, 0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x74, 0x74, 0x6c, 0x53, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x73, 0x12, 0x17, 0x0a, 0x04, 0x77, 0x61, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
+	0x48, 0x00, 0x52, 0x04, 0x77, 0x61, 0x69, 0x74, 0x88, 0x01, 0x01, 0x42, 0x07, 0x0a, 0x05, 0x5f,
+	0x77, 0x61, 0x69, 0x74, 0x22, 0x92, 0x01, 0x0a, 0x0e, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74,
+	0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x44, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2c, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63,
+	0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x64, 0x64, 0x52, 0x6f,
+	0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x20, 0x0a, 0x0b, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x06, 0x0a, 0x02, 0x6f, 0x6b, 0x10, 0x00, 0x12, 0x09,
+	0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x01, 0x22, 0x24, 0x0a, 0x12, 0x52, 0x65, 0x6d,
+	0x6f, 0x76, 0x65, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22,
+	0xb0, 0x01, 0x0a, 0x10, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52,
+	0x65, 0x70, 0x6c, 0x79, 0x12, 0x46, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x2e, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69,
+	0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52, 0x6f,
+	0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x78, 0x69, 0x73, 0x74, 0x65,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x65, 0x78, 0x69, 0x73, 0x74, 0x65, 0x64,
+	0x22, 0x20, 0x0a, 0x0b, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12,
+	0x06, 0x0a, 0x02, 0x6f, 0x6b, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x10, 0x01, 0x22, 0x42, 0x0a, 0x0f, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2f, 0x0a, 0x06, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67,
+	0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x06,
+	0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x22, 0x90, 0x01, 0x0a, 0x0d, 0x52, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x65, 0x72, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x43, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79,
+	0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x67, 0x69,
+	0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18, 0x0a,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x20, 0x0a, 0x0b, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x06, 0x0a, 0x02, 0x6f, 0x6b, 0x10, 0x00, 0x12, 0x09,
+	0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x01, 0x22, 0xc0, 0x01, 0x0a, 0x09, 0x52, 0x6f,
+	0x75, 0x74, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x2d, 0x0a, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66,
+	0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52,
+	0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x3a, 0x0a, 0x0a, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74,
+	0x65, 0x72, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72,
+	0x65, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x34, 0x0a, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65,
+	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x52, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x22, 0x43, 0x0a, 0x11,
+	0x4c, 0x69, 0x73, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x64, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x69, 0x64, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x12, 0x0a,
+	0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73,
+	0x74, 0x22, 0x46, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52,
+	0x65, 0x70, 0x6c, 0x79, 0x12, 0x33, 0x0a, 0x06, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69,
+	0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x49, 0x6e, 0x66,
+	0x6f, 0x52, 0x06, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x22, 0x21, 0x0a, 0x0f, 0x47, 0x65, 0x74,
+	0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x42, 0x0a, 0x0d,
+	0x47, 0x65, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x31, 0x0a,
+	0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63,
+	0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e,
+	0x52, 0x6f, 0x75, 0x74, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65,
+	0x22, 0x14, 0x0a, 0x12, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xc7, 0x02, 0x0a, 0x0a, 0x52, 0x6f, 0x75, 0x74, 0x65,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x35, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x21, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e,
+	0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x2e, 0x4b, 0x69, 0x6e, 0x64, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x2e, 0x0a, 0x04,
+	0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x31, 0x0a, 0x05,
+	0x72, 0x6f, 0x75, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61,
+	0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x75, 0x0a, 0x04, 0x4b, 0x69, 0x6e,
+	0x64, 0x12, 0x0c, 0x0a, 0x08, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x10, 0x00, 0x12,
+	0x10, 0x0a, 0x0c, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x44, 0x6f, 0x6e, 0x65, 0x10,
+	0x01, 0x12, 0x09, 0x0a, 0x05, 0x61, 0x64, 0x64, 0x65, 0x64, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07,
+	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x10, 0x03, 0x12, 0x0b, 0x0a, 0x07, 0x72, 0x65, 0x6d,
+	0x6f, 0x76, 0x65, 0x64, 0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x70, 0x75, 0x73, 0x68, 0x65, 0x64,
+	0x10, 0x05, 0x12, 0x0e, 0x0a, 0x0a, 0x70, 0x75, 0x73, 0x68, 0x46, 0x61, 0x69, 0x6c, 0x65, 0x64,
+	0x10, 0x06, 0x12, 0x0c, 0x0a, 0x08, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x10, 0x07,
+	0x22, 0x15, 0x0a, 0x13, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x71, 0x0a, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x32, 0x0a, 0x06, 0x70, 0x75,
+	0x73, 0x68, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x06, 0x70, 0x75, 0x73, 0x68, 0x65, 0x64, 0x12, 0x1a,
+	0x0a, 0x08, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x49, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x08, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x49, 0x64, 0x73, 0x22, 0x4a, 0x0a, 0x11, 0x4c, 0x69,
+	0x73, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12,
+	0x35, 0x0a, 0x08, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65,
+	0x63, 0x74, 0x6f, 0x72, 0x2e, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x22, 0x39, 0x0a, 0x13, 0x44, 0x69, 0x66, 0x66, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x66, 0x72, 0x6f,
+	0x6d, 0x12, 0x0e, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x74,
+	0x6f, 0x22, 0x27, 0x0a, 0x11, 0x44, 0x69, 0x66, 0x66, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x69, 0x66, 0x66, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64, 0x69, 0x66, 0x66, 0x22, 0x2b, 0x0a, 0x0f, 0x52, 0x6f,
+	0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xaa, 0x01, 0x0a, 0x0d, 0x52, 0x6f, 0x6c, 0x6c,
+	0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x43, 0x0a, 0x06, 0x72, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2b, 0x2e, 0x63, 0x61, 0x64, 0x64,
+	0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x6c,
+	0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x18,
+	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x22, 0x20, 0x0a, 0x0b, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x12, 0x06, 0x0a, 0x02, 0x6f, 0x6b, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x10, 0x01, 0x32, 0xf9, 0x06, 0x0a, 0x10, 0x43, 0x61, 0x64, 0x64, 0x79, 0x43, 0x66,
+	0x67, 0x49, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x50, 0x0a, 0x08, 0x41, 0x64, 0x64,
+	0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67,
+	0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79,
+	0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x64, 0x64, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x53, 0x0a, 0x09, 0x41,
+	0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x12, 0x22, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79,
+	0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x64, 0x64, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x63,
+	0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e,
+	0x41, 0x64, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00,
+	0x12, 0x59, 0x0a, 0x0b, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x12,
+	0x24, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67,
+	0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x54, 0x0a, 0x08, 0x52,
+	0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63,
+	0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x64,
+	0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x65,
+	0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x28, 0x01, 0x30,
+	0x01, 0x12, 0x56, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x12,
+	0x23, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69,
+	0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x6f, 0x75, 0x74,
+	0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x50, 0x0a, 0x08, 0x47, 0x65, 0x74,
+	0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67,
+	0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x6f, 0x75, 0x74,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79,
+	0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x55, 0x0a, 0x0b, 0x57,
+	0x61, 0x74, 0x63, 0x68, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x12, 0x24, 0x2e, 0x63, 0x61, 0x64,
+	0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x57, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1c, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x00,
+	0x30, 0x01, 0x12, 0x5c, 0x0a, 0x0c, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x73, 0x12, 0x25, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a,
+	0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x63, 0x61, 0x64, 0x64,
+	0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x69, 0x73,
+	0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00,
+	0x12, 0x5c, 0x0a, 0x0c, 0x44, 0x69, 0x66, 0x66, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73,
+	0x12, 0x25, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x2e, 0x44, 0x69, 0x66, 0x66, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63,
+	0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x44, 0x69, 0x66, 0x66, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x50,
+	0x0a, 0x08, 0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x64,
+	0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f,
+	0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e,
+	0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72,
+	0x2e, 0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00,
+	0x42, 0x40, 0x5a, 0x3e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6b,
+	0x69, 0x6e, 0x67, 0x38, 0x66, 0x69, 0x73, 0x68, 0x65, 0x72, 0x2f, 0x63, 0x61, 0x64, 0x64, 0x79,
+	0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2f, 0x63, 0x61, 0x64, 0x64, 0x79, 0x63, 0x66, 0x67, 0x69, 0x6e, 0x6a, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_caddycfginjector_proto_rawDescOnce sync.Once
+	file_caddycfginjector_proto_rawDescData = file_caddycfginjector_proto_rawDesc
+)
+
+func file_caddycfginjector_proto_rawDescGZIP() []byte {
+	file_caddycfginjector_proto_rawDescOnce.Do(func() {
+		file_caddycfginjector_proto_rawDescData = protoimpl.X.CompressGZIP(file_caddycfginjector_proto_rawDescData)
+	})
+	return file_caddycfginjector_proto_rawDescData
+}
+
+var file_caddycfginjector_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_caddycfginjector_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
+var file_caddycfginjector_proto_goTypes = []interface{}{
+	(Transport_Protocol)(0),           // 0: caddycfginjector.Transport.Protocol
+	(AddRouteReply_ReplyResult)(0),    // 1: caddycfginjector.AddRouteReply.ReplyResult
+	(AddRoutesReply_ReplyResult)(0),   // 2: caddycfginjector.AddRoutesReply.ReplyResult
+	(RemoveRouteReply_ReplyResult)(0), // 3: caddycfginjector.RemoveRouteReply.ReplyResult
+	(RegisterReply_ReplyResult)(0),    // 4: caddycfginjector.RegisterReply.ReplyResult
+	(RouteEvent_Kind)(0),              // 5: caddycfginjector.RouteEvent.Kind
+	(RollbackReply_ReplyResult)(0),    // 6: caddycfginjector.RollbackReply.ReplyResult
+	(*AddRouteRequest)(nil),           // 7: caddycfginjector.AddRouteRequest
+	(*Route)(nil),                     // 8: caddycfginjector.Route
+	(*Handle)(nil),                    // 9: caddycfginjector.Handle
+	(*ReverseProxy)(nil),              // 10: caddycfginjector.ReverseProxy
+	(*Transport)(nil),                 // 11: caddycfginjector.Transport
+	(*Upstream)(nil),                  // 12: caddycfginjector.Upstream
+	(*Dial)(nil),                      // 13: caddycfginjector.Dial
+	(*Match)(nil),                     // 14: caddycfginjector.Match
+	(*AddRouteReply)(nil),             // 15: caddycfginjector.AddRouteReply
+	(*AddRoutesRequest)(nil),          // 16: caddycfginjector.AddRoutesRequest
+	(*AddRoutesReply)(nil),            // 17: caddycfginjector.AddRoutesReply
+	(*RemoveRouteRequest)(nil),        // 18: caddycfginjector.RemoveRouteRequest
+	(*RemoveRouteReply)(nil),          // 19: caddycfginjector.RemoveRouteReply
+	(*RegisterRequest)(nil),           // 20: caddycfginjector.RegisterRequest
+	(*RegisterReply)(nil),             // 21: caddycfginjector.RegisterReply
+	(*RouteInfo)(nil),                 // 22: caddycfginjector.RouteInfo
+	(*ListRoutesRequest)(nil),         // 23: caddycfginjector.ListRoutesRequest
+	(*ListRoutesReply)(nil),           // 24: caddycfginjector.ListRoutesReply
+	(*GetRouteRequest)(nil),           // 25: caddycfginjector.GetRouteRequest
+	(*GetRouteReply)(nil),             // 26: caddycfginjector.GetRouteReply
+	(*WatchRoutesRequest)(nil),        // 27: caddycfginjector.WatchRoutesRequest
+	(*RouteEvent)(nil),                // 28: caddycfginjector.RouteEvent
+	(*ListVersionsRequest)(nil),       // 29: caddycfginjector.ListVersionsRequest
+	(*Version)(nil),                   // 30: caddycfginjector.Version
+	(*ListVersionsReply)(nil),         // 31: caddycfginjector.ListVersionsReply
+	(*DiffVersionsRequest)(nil),       // 32: caddycfginjector.DiffVersionsRequest
+	(*DiffVersionsReply)(nil),         // 33: caddycfginjector.DiffVersionsReply
+	(*RollbackRequest)(nil),           // 34: caddycfginjector.RollbackRequest
+	(*RollbackReply)(nil),             // 35: caddycfginjector.RollbackReply
+	(*timestamppb.Timestamp)(nil),     // 36: google.protobuf.Timestamp
+}
+var file_caddycfginjector_proto_depIdxs = []int32{
+	8,  // 0: caddycfginjector.AddRouteRequest.route:type_name -> caddycfginjector.Route
+	9,  // 1: caddycfginjector.Route.handles:type_name -> caddycfginjector.Handle
+	14, // 2: caddycfginjector.Route.matches:type_name -> caddycfginjector.Match
+	10, // 3: caddycfginjector.Handle.reverseProxy:type_name -> caddycfginjector.ReverseProxy
+	11, // 4: caddycfginjector.ReverseProxy.transport:type_name -> caddycfginjector.Transport
+	12, // 5: caddycfginjector.ReverseProxy.upstreams:type_name -> caddycfginjector.Upstream
+	0,  // 6: caddycfginjector.Transport.protocol:type_name -> caddycfginjector.Transport.Protocol
+	13, // 7: caddycfginjector.Upstream.dial:type_name -> caddycfginjector.Dial
+	1,  // 8: caddycfginjector.AddRouteReply.result:type_name -> caddycfginjector.AddRouteReply.ReplyResult
+	8,  // 9: caddycfginjector.AddRoutesRequest.routes:type_name -> caddycfginjector.Route
+	2,  // 10: caddycfginjector.AddRoutesReply.result:type_name -> caddycfginjector.AddRoutesReply.ReplyResult
+	3,  // 11: caddycfginjector.RemoveRouteReply.result:type_name -> caddycfginjector.RemoveRouteReply.ReplyResult
+	8,  // 12: caddycfginjector.RegisterRequest.routes:type_name -> caddycfginjector.Route
+	4,  // 13: caddycfginjector.RegisterReply.result:type_name -> caddycfginjector.RegisterReply.ReplyResult
+	8,  // 14: caddycfginjector.RouteInfo.route:type_name -> caddycfginjector.Route
+	36, // 15: caddycfginjector.RouteInfo.registered:type_name -> google.protobuf.Timestamp
+	36, // 16: caddycfginjector.RouteInfo.expires:type_name -> google.protobuf.Timestamp
+	22, // 17: caddycfginjector.ListRoutesReply.routes:type_name -> caddycfginjector.RouteInfo
+	22, // 18: caddycfginjector.GetRouteReply.route:type_name -> caddycfginjector.RouteInfo
+	5,  // 19: caddycfginjector.RouteEvent.kind:type_name -> caddycfginjector.RouteEvent.Kind
+	36, // 20: caddycfginjector.RouteEvent.time:type_name -> google.protobuf.Timestamp
+	22, // 21: caddycfginjector.RouteEvent.route:type_name -> caddycfginjector.RouteInfo
+	36, // 22: caddycfginjector.Version.pushed:type_name -> google.protobuf.Timestamp
+	30, // 23: caddycfginjector.ListVersionsReply.versions:type_name -> caddycfginjector.Version
+	6,  // 24: caddycfginjector.RollbackReply.result:type_name -> caddycfginjector.RollbackReply.ReplyResult
+	7,  // 25: caddycfginjector.CaddyCfgInjector.AddRoute:input_type -> caddycfginjector.AddRouteRequest
+	16, // 26: caddycfginjector.CaddyCfgInjector.AddRoutes:input_type -> caddycfginjector.AddRoutesRequest
+	18, // 27: caddycfginjector.CaddyCfgInjector.RemoveRoute:input_type -> caddycfginjector.RemoveRouteRequest
+	20, // 28: caddycfginjector.CaddyCfgInjector.Register:input_type -> caddycfginjector.RegisterRequest
+	23, // 29: caddycfginjector.CaddyCfgInjector.ListRoutes:input_type -> caddycfginjector.ListRoutesRequest
+	25, // 30: caddycfginjector.CaddyCfgInjector.GetRoute:input_type -> caddycfginjector.GetRouteRequest
+	27, // 31: caddycfginjector.CaddyCfgInjector.WatchRoutes:input_type -> caddycfginjector.WatchRoutesRequest
+	29, // 32: caddycfginjector.CaddyCfgInjector.ListVersions:input_type -> caddycfginjector.ListVersionsRequest
+	32, // 33: caddycfginjector.CaddyCfgInjector.DiffVersions:input_type -> caddycfginjector.DiffVersionsRequest
+	34, // 34: caddycfginjector.CaddyCfgInjector.Rollback:input_type -> caddycfginjector.RollbackRequest
+	15, // 35: caddycfginjector.CaddyCfgInjector.AddRoute:output_type -> caddycfginjector.AddRouteReply
+	17, // 36: caddycfginjector.CaddyCfgInjector.AddRoutes:output_type -> caddycfginjector.AddRoutesReply
+	19, // 37: caddycfginjector.CaddyCfgInjector.RemoveRoute:output_type -> caddycfginjector.RemoveRouteReply
+	21, // 38: caddycfginjector.CaddyCfgInjector.Register:output_type -> caddycfginjector.RegisterReply
+	24, // 39: caddycfginjector.CaddyCfgInjector.ListRoutes:output_type -> caddycfginjector.ListRoutesReply
+	26, // 40: caddycfginjector.CaddyCfgInjector.GetRoute:output_type -> caddycfginjector.GetRouteReply
+	28, // 41: caddycfginjector.CaddyCfgInjector.WatchRoutes:output_type -> caddycfginjector.RouteEvent
+	31, // 42: caddycfginjector.CaddyCfgInjector.ListVersions:output_type -> caddycfginjector.ListVersionsReply
+	33, // 43: caddycfginjector.CaddyCfgInjector.DiffVersions:output_type -> caddycfginjector.DiffVersionsReply
+	35, // 44: caddycfginjector.CaddyCfgInjector.Rollback:output_type -> caddycfginjector.RollbackReply
+	35, // [35:45] is the sub-list for method output_type
+	25, // [25:35] is the sub-list for method input_type
+	25, // [25:25] is the sub-list for extension type_name
+	25, // [25:25] is the sub-list for extension extendee
+	0,  // [0:25] is the sub-list for field type_name
+}
+
+func init() { file_caddycfginjector_proto_init() }
+func file_caddycfginjector_proto_init() {
+	if File_caddycfginjector_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_caddycfginjector_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddRouteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Route); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Handle); i {
 			case 0:
 				return &v.state
@@ -837,17 +2456,259 @@ func file_caddycfginjector_proto_init() {
 				return nil
 			}
 		}
+		file_caddycfginjector_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddRoutesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddRoutesReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveRouteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveRouteReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegisterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegisterReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RouteInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListRoutesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListRoutesReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetRouteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetRouteReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchRoutesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RouteEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListVersionsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Version); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListVersionsReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DiffVersionsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DiffVersionsReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RollbackRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_caddycfginjector_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RollbackReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
+	file_caddycfginjector_proto_msgTypes[0].OneofWrappers = []interface{}{}
 	file_caddycfginjector_proto_msgTypes[2].OneofWrappers = []interface{}{
 		(*Handle_ReverseProxy)(nil),
 	}
+	file_caddycfginjector_proto_msgTypes[9].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_caddycfginjector_proto_rawDesc,
-			NumEnums:      2,
-			NumMessages:   9,
+			NumEnums:      7,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,6 +23,24 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CaddyCfgInjectorClient interface {
 	AddRoute(ctx context.Context, in *AddRouteRequest, opts ...grpc.CallOption) (*AddRouteReply, error)
+	// AddRoutes adds all routes as one unit, resulting in a single Caddy patch.
+	// No route is added when any of them is invalid.
+	AddRoutes(ctx context.Context, in *AddRoutesRequest, opts ...grpc.CallOption) (*AddRoutesReply, error)
+	RemoveRoute(ctx context.Context, in *RemoveRouteRequest, opts ...grpc.CallOption) (*RemoveRouteReply, error)
+	// Register keeps routes sent over the stream active while the stream is open.
+	// Routes are removed once the stream ends or the connection stops responding to keepalives.
+	Register(ctx context.Context, opts ...grpc.CallOption) (CaddyCfgInjector_RegisterClient, error)
+	ListRoutes(ctx context.Context, in *ListRoutesRequest, opts ...grpc.CallOption) (*ListRoutesReply, error)
+	GetRoute(ctx context.Context, in *GetRouteRequest, opts ...grpc.CallOption) (*GetRouteReply, error)
+	// WatchRoutes streams a snapshot of current routes followed by events as they happen.
+	WatchRoutes(ctx context.Context, in *WatchRoutesRequest, opts ...grpc.CallOption) (CaddyCfgInjector_WatchRoutesClient, error)
+	// ListVersions lists confs Caddy accepted, most recent first.
+	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsReply, error)
+	// DiffVersions shows the difference between two confs Caddy accepted.
+	DiffVersions(ctx context.Context, in *DiffVersionsRequest, opts ...grpc.CallOption) (*DiffVersionsReply, error)
+	// Rollback pushes a conf Caddy accepted before again and replaces current routes with its routes.
+	// Apps still announcing routes removed by it add them back.
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackReply, error)
 }
 
 type caddyCfgInjectorClient struct {
@@ -42,11 +60,155 @@ func (c *caddyCfgInjectorClient) AddRoute(ctx context.Context, in *AddRouteReque
 	return out, nil
 }
 
+func (c *caddyCfgInjectorClient) AddRoutes(ctx context.Context, in *AddRoutesRequest, opts ...grpc.CallOption) (*AddRoutesReply, error) {
+	out := new(AddRoutesReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/AddRoutes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *caddyCfgInjectorClient) RemoveRoute(ctx context.Context, in *RemoveRouteRequest, opts ...grpc.CallOption) (*RemoveRouteReply, error) {
+	out := new(RemoveRouteReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/RemoveRoute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *caddyCfgInjectorClient) Register(ctx context.Context, opts ...grpc.CallOption) (CaddyCfgInjector_RegisterClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CaddyCfgInjector_ServiceDesc.Streams[0], "/caddycfginjector.CaddyCfgInjector/Register", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &caddyCfgInjectorRegisterClient{stream}
+	return x, nil
+}
+
+type CaddyCfgInjector_RegisterClient interface {
+	Send(*RegisterRequest) error
+	Recv() (*RegisterReply, error)
+	grpc.ClientStream
+}
+
+type caddyCfgInjectorRegisterClient struct {
+	grpc.ClientStream
+}
+
+func (x *caddyCfgInjectorRegisterClient) Send(m *RegisterRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *caddyCfgInjectorRegisterClient) Recv() (*RegisterReply, error) {
+	m := new(RegisterReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *caddyCfgInjectorClient) ListRoutes(ctx context.Context, in *ListRoutesRequest, opts ...grpc.CallOption) (*ListRoutesReply, error) {
+	out := new(ListRoutesReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/ListRoutes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *caddyCfgInjectorClient) GetRoute(ctx context.Context, in *GetRouteRequest, opts ...grpc.CallOption) (*GetRouteReply, error) {
+	out := new(GetRouteReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/GetRoute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *caddyCfgInjectorClient) WatchRoutes(ctx context.Context, in *WatchRoutesRequest, opts ...grpc.CallOption) (CaddyCfgInjector_WatchRoutesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CaddyCfgInjector_ServiceDesc.Streams[1], "/caddycfginjector.CaddyCfgInjector/WatchRoutes", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &caddyCfgInjectorWatchRoutesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CaddyCfgInjector_WatchRoutesClient interface {
+	Recv() (*RouteEvent, error)
+	grpc.ClientStream
+}
+
+type caddyCfgInjectorWatchRoutesClient struct {
+	grpc.ClientStream
+}
+
+func (x *caddyCfgInjectorWatchRoutesClient) Recv() (*RouteEvent, error) {
+	m := new(RouteEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *caddyCfgInjectorClient) ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsReply, error) {
+	out := new(ListVersionsReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/ListVersions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *caddyCfgInjectorClient) DiffVersions(ctx context.Context, in *DiffVersionsRequest, opts ...grpc.CallOption) (*DiffVersionsReply, error) {
+	out := new(DiffVersionsReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/DiffVersions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *caddyCfgInjectorClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackReply, error) {
+	out := new(RollbackReply)
+	err := c.cc.Invoke(ctx, "/caddycfginjector.CaddyCfgInjector/Rollback", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CaddyCfgInjectorServer is the server API for CaddyCfgInjector service.
 // All implementations must embed UnimplementedCaddyCfgInjectorServer
 // for forward compatibility
 type CaddyCfgInjectorServer interface {
 	AddRoute(context.Context, *AddRouteRequest) (*AddRouteReply, error)
+	// AddRoutes adds all routes as one unit, resulting in a single Caddy patch.
+	// No route is added when any of them is invalid.
+	AddRoutes(context.Context, *AddRoutesRequest) (*AddRoutesReply, error)
+	RemoveRoute(context.Context, *RemoveRouteRequest) (*RemoveRouteReply, error)
+	// Register keeps routes sent over the stream active while the stream is open.
+	// Routes are removed once the stream ends or the connection stops responding to keepalives.
+	Register(CaddyCfgInjector_RegisterServer) error
+	ListRoutes(context.Context, *ListRoutesRequest) (*ListRoutesReply, error)
+	GetRoute(context.Context, *GetRouteRequest) (*GetRouteReply, error)
+	// WatchRoutes streams a snapshot of current routes followed by events as they happen.
+	WatchRoutes(*WatchRoutesRequest, CaddyCfgInjector_WatchRoutesServer) error
+	// ListVersions lists confs Caddy accepted, most recent first.
+	ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsReply, error)
+	// DiffVersions shows the difference between two confs Caddy accepted.
+	DiffVersions(context.Context, *DiffVersionsRequest) (*DiffVersionsReply, error)
+	// Rollback pushes a conf Caddy accepted before again and replaces current routes with its routes.
+	// Apps still announcing routes removed by it add them back.
+	Rollback(context.Context, *RollbackRequest) (*RollbackReply, error)
 	mustEmbedUnimplementedCaddyCfgInjectorServer()
 }
 
@@ -57,6 +219,33 @@ type UnimplementedCaddyCfgInjectorServer struct {
 func (UnimplementedCaddyCfgInjectorServer) AddRoute(context.Context, *AddRouteRequest) (*AddRouteReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddRoute not implemented")
 }
+func (UnimplementedCaddyCfgInjectorServer) AddRoutes(context.Context, *AddRoutesRequest) (*AddRoutesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRoutes not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) RemoveRoute(context.Context, *RemoveRouteRequest) (*RemoveRouteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveRoute not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) Register(CaddyCfgInjector_RegisterServer) error {
+	return status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) ListRoutes(context.Context, *ListRoutesRequest) (*ListRoutesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRoutes not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) GetRoute(context.Context, *GetRouteRequest) (*GetRouteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoute not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) WatchRoutes(*WatchRoutesRequest, CaddyCfgInjector_WatchRoutesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRoutes not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) DiffVersions(context.Context, *DiffVersionsRequest) (*DiffVersionsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffVersions not implemented")
+}
+func (UnimplementedCaddyCfgInjectorServer) Rollback(context.Context, *RollbackRequest) (*RollbackReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
 func (UnimplementedCaddyCfgInjectorServer) mustEmbedUnimplementedCaddyCfgInjectorServer() {}
 
 // UnsafeCaddyCfgInjectorServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +277,179 @@ func _CaddyCfgInjector_AddRoute_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CaddyCfgInjector_AddRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRoutesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).AddRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/AddRoutes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).AddRoutes(ctx, req.(*AddRoutesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaddyCfgInjector_RemoveRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).RemoveRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/RemoveRoute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).RemoveRoute(ctx, req.(*RemoveRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaddyCfgInjector_Register_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CaddyCfgInjectorServer).Register(&caddyCfgInjectorRegisterServer{stream})
+}
+
+type CaddyCfgInjector_RegisterServer interface {
+	Send(*RegisterReply) error
+	Recv() (*RegisterRequest, error)
+	grpc.ServerStream
+}
+
+type caddyCfgInjectorRegisterServer struct {
+	grpc.ServerStream
+}
+
+func (x *caddyCfgInjectorRegisterServer) Send(m *RegisterReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *caddyCfgInjectorRegisterServer) Recv() (*RegisterRequest, error) {
+	m := new(RegisterRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CaddyCfgInjector_ListRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRoutesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).ListRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/ListRoutes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).ListRoutes(ctx, req.(*ListRoutesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaddyCfgInjector_GetRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).GetRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/GetRoute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).GetRoute(ctx, req.(*GetRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaddyCfgInjector_WatchRoutes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRoutesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CaddyCfgInjectorServer).WatchRoutes(m, &caddyCfgInjectorWatchRoutesServer{stream})
+}
+
+type CaddyCfgInjector_WatchRoutesServer interface {
+	Send(*RouteEvent) error
+	grpc.ServerStream
+}
+
+type caddyCfgInjectorWatchRoutesServer struct {
+	grpc.ServerStream
+}
+
+func (x *caddyCfgInjectorWatchRoutesServer) Send(m *RouteEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CaddyCfgInjector_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/ListVersions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).ListVersions(ctx, req.(*ListVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaddyCfgInjector_DiffVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).DiffVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/DiffVersions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).DiffVersions(ctx, req.(*DiffVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaddyCfgInjector_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaddyCfgInjectorServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/caddycfginjector.CaddyCfgInjector/Rollback",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaddyCfgInjectorServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CaddyCfgInjector_ServiceDesc is the grpc.ServiceDesc for CaddyCfgInjector service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,7 +461,47 @@ var CaddyCfgInjector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AddRoute",
 			Handler:    _CaddyCfgInjector_AddRoute_Handler,
 		},
+		{
+			MethodName: "AddRoutes",
+			Handler:    _CaddyCfgInjector_AddRoutes_Handler,
+		},
+		{
+			MethodName: "RemoveRoute",
+			Handler:    _CaddyCfgInjector_RemoveRoute_Handler,
+		},
+		{
+			MethodName: "ListRoutes",
+			Handler:    _CaddyCfgInjector_ListRoutes_Handler,
+		},
+		{
+			MethodName: "GetRoute",
+			Handler:    _CaddyCfgInjector_GetRoute_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _CaddyCfgInjector_ListVersions_Handler,
+		},
+		{
+			MethodName: "DiffVersions",
+			Handler:    _CaddyCfgInjector_DiffVersions_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _CaddyCfgInjector_Rollback_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Register",
+			Handler:       _CaddyCfgInjector_Register_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchRoutes",
+			Handler:       _CaddyCfgInjector_WatchRoutes_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "caddycfginjector.proto",
 }
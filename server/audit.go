@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/audit"
+	"github.com/king8fisher/caddycfginjector/db"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientIdHeader is the metadata key clients without a TLS certificate may
+// identify themselves with in the audit log.
+const clientIdHeader = "x-client-id"
+
+// auditChange holds entries of a change of routes waiting in
+// Server.auditQueue until it is known whether Caddy accepted the change.
+type auditChange struct {
+	entries []audit.Entry
+	// refused changes are complete once queued
+	refused bool
+	// done is set once entries are complete, guarded by Server.auditMutex
+	done bool
+}
+
+// identity returns the common name of the client TLS certificate, or the
+// client id sent in metadata.
+func identity(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			return info.State.PeerCertificates[0].Subject.CommonName
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(clientIdHeader); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// routeJSON returns r in Caddy JSON, or null if r is nil.
+func routeJSON(r *db.Route) json.RawMessage {
+	if r == nil {
+		return json.RawMessage("null")
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+// findRoute returns the route with id in the conf of the store, or nil.
+func (s *Server) findRoute(id string) *db.Route {
+	if r, ok := s.store.FindRoute(id); ok {
+		return &r
+	}
+	return nil
+}
+
+// audited calls change, which changes routes of the store on behalf of the
+// client of ctx, and queues audit entries of the routes it changed with the
+// routes before and after as change returned them. Audited changes are made
+// one at a time, so that entries are queued in the order of changes. ids
+// name the routes a refused change attempted to change.
+//
+// The returned audit is to be passed to endAudit. It is nil when the audit
+// log is disabled or nothing changed.
+func (s *Server) audited(ctx context.Context, action string, ids []string, change func() ([]db.RouteChange, error)) (*auditChange, error) {
+	if s.audit == nil {
+		_, err := change()
+		return nil, err
+	}
+	s.changeMutex.Lock()
+	defer s.changeMutex.Unlock()
+	changes, err := change()
+
+	now := time.Now()
+	entry := func(id string) audit.Entry {
+		return audit.Entry{Time: now, Action: action, Peer: peerAddr(ctx), Identity: identity(ctx), RouteId: id}
+	}
+	c := &auditChange{refused: err != nil}
+	if err != nil {
+		for _, id := range ids {
+			e := entry(id)
+			e.Before = routeJSON(s.findRoute(id))
+			e.After = e.Before
+			e.Error = err.Error()
+			c.entries = append(c.entries, e)
+		}
+	}
+	for _, rc := range changes {
+		e := entry(rc.Id)
+		e.Before, e.After = routeJSON(rc.Before), routeJSON(rc.After)
+		if string(e.Before) == string(e.After) {
+			// Heartbeats re-adding the same route change nothing
+			continue
+		}
+		c.entries = append(c.entries, e)
+	}
+	return s.queueAudit(c), err
+}
+
+// queueAudit appends c to the queue of entries to be written, unless it is
+// empty, and returns it.
+func (s *Server) queueAudit(c *auditChange) *auditChange {
+	if len(c.entries) == 0 {
+		return nil
+	}
+	s.auditMutex.Lock()
+	defer s.auditMutex.Unlock()
+	c.done = c.refused
+	s.auditQueue = append(s.auditQueue, c)
+	s.flushAuditNonBlocking()
+	return c
+}
+
+// endAudit completes c once Caddy applied the conf with sequence number
+// seq, or failed to. Entries are written as soon as every change queued
+// before c is complete as well.
+func (s *Server) endAudit(c *auditChange, seq uint64) {
+	if c == nil || c.refused {
+		return
+	}
+	if seq == 0 {
+		s.completeAudit(c, fmt.Errorf("queued until caddy conf is received"))
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.waitTimeout)
+		defer cancel()
+		s.completeAudit(c, s.client.Wait(ctx, seq))
+	}()
+}
+
+// completeAudit records the outcome of pushing c to Caddy and writes
+// complete entries from the head of the queue.
+func (s *Server) completeAudit(c *auditChange, pushErr error) {
+	s.auditMutex.Lock()
+	defer s.auditMutex.Unlock()
+	for i := range c.entries {
+		e := &c.entries[i]
+		e.Pushed = pushErr == nil
+		if pushErr != nil {
+			e.PushError = pushErr.Error()
+		} else if rerr := s.store.RouteRejection(e.RouteId); rerr != nil {
+			e.Pushed = false
+			e.PushError = rerr.Error()
+		}
+	}
+	c.done = true
+	s.flushAuditNonBlocking()
+}
+
+// flushAuditNonBlocking writes entries of complete changes at the head of
+// the queue.
+func (s *Server) flushAuditNonBlocking() {
+	for len(s.auditQueue) > 0 && s.auditQueue[0].done {
+		for _, e := range s.auditQueue[0].entries {
+			if err := s.audit.Write(e); err != nil {
+				slog.Error("unable to write audit log", "err", err)
+			}
+		}
+		s.auditQueue[0] = nil
+		s.auditQueue = s.auditQueue[1:]
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/king8fisher/caddycfginjector/caddy"
+	"github.com/king8fisher/caddycfginjector/db"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// toStatus converts err returned by db or caddy packages to a gRPC status error
+// with a code and details clients can act upon.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	var fe *db.FieldError
+	var re *db.RouteRejectedError
+	var ce *caddy.RejectedError
+	var be *db.BaseRouteError
+	switch {
+	case errors.As(err, &fe):
+		return withDetails(status.New(codes.InvalidArgument, err.Error()), &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: fe.Field, Description: fe.Description},
+			},
+		})
+	case errors.As(err, &re):
+		return withDetails(status.New(codes.InvalidArgument, err.Error()), &errdetails.ErrorInfo{
+			Reason:   "CADDY_REJECTED",
+			Domain:   "caddy",
+			Metadata: map[string]string{"id": re.Id, "error": re.Reason},
+		})
+	case errors.As(err, &be):
+		return withDetails(status.New(codes.PermissionDenied, err.Error()), &errdetails.ErrorInfo{
+			Reason:   "BASE_ROUTE",
+			Domain:   "caddy",
+			Metadata: map[string]string{"id": be.Id},
+		})
+	case errors.Is(err, db.ErrEmptyConf):
+		return withDetails(status.New(codes.FailedPrecondition, err.Error()), &errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{
+				{Type: "CADDY_CONF", Subject: "caddy", Description: "conf has not been received from Caddy yet"},
+			},
+		})
+	case errors.As(err, &ce):
+		return withDetails(status.New(codes.FailedPrecondition, err.Error()), &errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{
+				{Type: "CADDY_LOAD", Subject: "caddy", Description: ce.Body},
+			},
+		})
+	case errors.Is(err, caddy.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, caddy.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, caddy.ErrVersionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if st, ok := status.FromError(err); ok {
+		return st.Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// withDetails attaches details to st, falling back to st alone if they cannot be marshaled.
+func withDetails(st *status.Status, details ...protoiface.MessageV1) error {
+	if d, err := st.WithDetails(details...); err == nil {
+		return d.Err()
+	}
+	return st.Err()
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"github.com/king8fisher/caddycfginjector/caddy"
+	"github.com/king8fisher/caddycfginjector/db"
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) ListVersions(_ context.Context, _ *pb.ListVersionsRequest) (*pb.ListVersionsReply, error) {
+	reply := &pb.ListVersionsReply{}
+	for _, v := range s.client.Versions() {
+		pv := &pb.Version{Number: v.Number, Pushed: timestamppb.New(v.Pushed)}
+		if routes, err := db.ConfRoutes(v.Conf); err == nil {
+			for _, r := range routes {
+				if r.Id != "" {
+					pv.RouteIds = append(pv.RouteIds, r.Id)
+				}
+			}
+		}
+		reply.Versions = append(reply.Versions, pv)
+	}
+	return reply, nil
+}
+
+func (s *Server) DiffVersions(_ context.Context, in *pb.DiffVersionsRequest) (*pb.DiffVersionsReply, error) {
+	from, ok := s.client.GetVersion(in.From)
+	if !ok || in.From == 0 {
+		return nil, toStatus(caddy.ErrVersionNotFound)
+	}
+	to, ok := s.client.GetVersion(in.To)
+	if !ok {
+		return nil, toStatus(caddy.ErrVersionNotFound)
+	}
+	diff, err := caddy.Diff(from, to)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DiffVersionsReply{Diff: diff}, nil
+}
+
+// Rollback waits until Caddy has applied the version regardless of --wait, so
+// that the outcome is known to the operator.
+func (s *Server) Rollback(ctx context.Context, in *pb.RollbackRequest) (*pb.RollbackReply, error) {
+	ids := s.rollbackIds(in.Version)
+	var seq uint64
+	change, err := s.audited(ctx, "rollback", ids, func() ([]db.RouteChange, error) {
+		var changes []db.RouteChange
+		for _, id := range ids {
+			changes = append(changes, db.RouteChange{Id: id, Before: s.findRoute(id)})
+		}
+		var err error
+		// Routes owned again get the lease of routes added without one
+		if seq, err = s.client.Rollback(in.Version, db.Registration{TTL: s.defaultTTL}); err != nil {
+			return nil, err
+		}
+		for i := range changes {
+			changes[i].After = s.findRoute(changes[i].Id)
+		}
+		return changes, nil
+	})
+	s.endAudit(change, seq)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, s.waitTimeout)
+		defer cancel()
+		err = s.client.Wait(ctx, seq)
+	}
+	if err != nil {
+		if s.legacyReplies {
+			return &pb.RollbackReply{
+				Result:  pb.RollbackReply_error,
+				Message: err.Error(),
+			}, nil
+		}
+		return nil, toStatus(err)
+	}
+	latest, _ := s.client.GetVersion(0)
+	return &pb.RollbackReply{
+		Result:  pb.RollbackReply_ok,
+		Message: "applied",
+		Version: latest.Number,
+	}, nil
+}
+
+// rollbackIds returns ids of routes in the current conf and in version, which
+// rolling back to version may change.
+func (s *Server) rollbackIds(version uint64) []string {
+	seen := map[string]bool{}
+	var ids []string
+	add := func(conf string) {
+		routes, _ := db.ConfRoutes(conf)
+		for _, r := range routes {
+			if r.Id != "" && !seen[r.Id] {
+				seen[r.Id] = true
+				ids = append(ids, r.Id)
+			}
+		}
+	}
+	if conf, err := s.store.ReadConf(); err == nil {
+		add(conf)
+	}
+	if v, ok := s.client.GetVersion(version); ok {
+		add(v.Conf)
+	}
+	return ids
+}
@@ -0,0 +1,483 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/audit"
+	"github.com/king8fisher/caddycfginjector/caddy"
+	"github.com/king8fisher/caddycfginjector/db"
+	"github.com/king8fisher/caddycfginjector/events"
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the gRPC service adding routes of clients to the conf of
+// a db.Store, which a caddy.Client keeps in sync with Caddy. Create it with New.
+type Server struct {
+	pb.UnimplementedCaddyCfgInjectorServer
+	store  *db.Store
+	client *caddy.Client
+
+	// defaultTTL is used for routes added without AddRouteRequest.TtlSeconds
+	defaultTTL time.Duration
+
+	// legacyReplies reports errors in replies with a nil error instead of
+	// gRPC status errors, as the server did before
+	legacyReplies bool
+	// wait makes AddRoute wait for Caddy to apply the route unless the
+	// request says otherwise
+	wait        bool
+	waitTimeout time.Duration
+
+	// audit records every change of routes, nil disables it
+	audit *audit.Log
+	// changeMutex makes audited changes one at a time, see audited
+	changeMutex sync.Mutex
+	auditMutex  sync.Mutex
+	// auditQueue holds changes in the order they were made until their
+	// entries are written
+	auditQueue []*auditChange
+
+	registeredMutex sync.Mutex
+	// registered maps route id to the Register stream that currently keeps it
+	registered map[string]*registration
+}
+
+// Options configure Server, see New.
+type Options struct {
+	// DefaultTTL is the lease of routes added without a TTL, zero disables expiry
+	DefaultTTL time.Duration
+	// LegacyReplies reports errors in replies with result=error instead of
+	// gRPC status codes
+	LegacyReplies bool
+	// Wait makes AddRoute wait for Caddy to apply added routes before
+	// replying, unless the request says otherwise, for at most WaitTimeout
+	Wait bool
+	// WaitTimeout also bounds Rollback and waiting for the outcome of audited
+	// changes. Zero means DefaultWaitTimeout.
+	WaitTimeout time.Duration
+	// Audit records every change of routes, nil disables it
+	Audit *audit.Log
+}
+
+// DefaultWaitTimeout is used unless Options.WaitTimeout is set.
+const DefaultWaitTimeout = 10 * time.Second
+
+// New returns a server adding routes to store, which client pushes to Caddy.
+// client is expected to run Patch, and usually Poll, in the background.
+func New(store *db.Store, client *caddy.Client, opts Options) *Server {
+	if opts.WaitTimeout <= 0 {
+		opts.WaitTimeout = DefaultWaitTimeout
+	}
+	return &Server{
+		store:         store,
+		client:        client,
+		defaultTTL:    opts.DefaultTTL,
+		legacyReplies: opts.LegacyReplies,
+		wait:          opts.Wait,
+		waitTimeout:   opts.WaitTimeout,
+		audit:         opts.Audit,
+		registered:    map[string]*registration{},
+	}
+}
+
+// SweepExpiredRoutes removes routes whose lease ended every interval until
+// ctx is done and pushes the conf without them to Caddy.
+func (s *Server) SweepExpiredRoutes(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.expireRoutes(now)
+		}
+	}
+}
+
+// expireRoutes removes routes whose lease ended before now as an audited
+// change, so that its entries are queued in order with changes of clients.
+func (s *Server) expireRoutes(now time.Time) {
+	var expired []db.RouteChange
+	change, _ := s.audited(context.Background(), "expire", nil, func() ([]db.RouteChange, error) {
+		expired = s.store.ExpireRoutes(now)
+		return expired, nil
+	})
+	if len(expired) == 0 {
+		return
+	}
+	var ids []string
+	for _, c := range expired {
+		ids = append(ids, c.Id)
+	}
+	slog.Info("routes lease expired", "ids", ids)
+	seq, _ := s.pushCaddyConf()
+	s.endAudit(change, seq)
+}
+
+// registration identifies a single Register stream.
+type registration struct {
+	peer string
+}
+
+// pushCaddyConf sends current conf to Caddy and returns its sequence number
+// for caddy.Client.Wait. Nothing is sent while the conf is empty, since db holds added
+// routes pending until the conf is received from Caddy, and 0 is returned.
+func (s *Server) pushCaddyConf() (uint64, error) {
+	cf, err := s.store.ReadConf()
+	if errors.Is(err, db.ErrEmptyConf) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return s.client.Push(cf), nil
+}
+
+// apply pushes current conf to Caddy and returns its sequence number, as
+// pushCaddyConf does, and a message for the reply.
+//
+// When waiting is requested, or is the server default in case wait is nil, it
+// waits until Caddy has applied the conf and reports an error if Caddy failed
+// or rejected any of the routes with ids.
+func (s *Server) apply(ctx context.Context, wait *bool, ids []string) (uint64, string, error) {
+	seq, err := s.pushCaddyConf()
+	if err != nil {
+		return 0, "", err
+	}
+	if wait == nil {
+		wait = &s.wait
+	}
+	if !*wait {
+		if seq == 0 {
+			return seq, "queued until caddy conf is received", nil
+		}
+		if st := s.client.CurrentState(); st.Failures > 0 {
+			return seq, fmt.Sprintf("ok, caddy is not updated yet: %v", st.LastError), nil
+		}
+		return seq, "ok", nil
+	}
+	if seq == 0 {
+		return seq, "", fmt.Errorf("route queued: %w", db.ErrEmptyConf)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.waitTimeout)
+	defer cancel()
+	if err := s.client.Wait(ctx, seq); err != nil {
+		return seq, "", err
+	}
+	for _, id := range ids {
+		if err := s.store.RouteRejection(id); err != nil {
+			return seq, "", err
+		}
+	}
+	return seq, "applied", nil
+}
+
+// routeIds returns ids of routes.
+func routeIds(routes []*pb.Route) []string {
+	var ids []string
+	for _, r := range routes {
+		ids = append(ids, r.GetId())
+	}
+	return ids
+}
+
+// peerAddr returns address of the client calling the RPC.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// routeTTL returns the lease requested by a client unless it is 0.
+func (s *Server) routeTTL(ttlSeconds uint32) time.Duration {
+	if ttlSeconds == 0 {
+		return s.defaultTTL
+	}
+	return time.Duration(ttlSeconds) * time.Second
+}
+
+func (s *Server) AddRoute(ctx context.Context, in *pb.AddRouteRequest) (*pb.AddRouteReply, error) {
+	var ids []string
+	if in.Route != nil {
+		ids = []string{in.Route.Id}
+	}
+	change, err := s.audited(ctx, "add", ids, func() ([]db.RouteChange, error) {
+		c, err := s.store.AddRoute(in.Route, db.Registration{
+			Peer: peerAddr(ctx),
+			TTL:  s.routeTTL(in.TtlSeconds),
+		})
+		return []db.RouteChange{c}, err
+	})
+	var seq uint64
+	var msg string
+	if err == nil {
+		// Route is owned by AddRoute heartbeats from now on
+		s.setRegistration(in.Route.Id, nil)
+		seq, msg, err = s.apply(ctx, in.Wait, []string{in.Route.Id})
+	}
+	s.endAudit(change, seq)
+	if err != nil {
+		if s.legacyReplies {
+			return &pb.AddRouteReply{
+				Result:  pb.AddRouteReply_error,
+				Message: err.Error(),
+			}, nil
+		}
+		return nil, toStatus(err)
+	}
+	return &pb.AddRouteReply{
+		Result:  pb.AddRouteReply_ok,
+		Message: msg,
+	}, nil
+}
+
+func (s *Server) AddRoutes(ctx context.Context, in *pb.AddRoutesRequest) (*pb.AddRoutesReply, error) {
+	change, err := s.audited(ctx, "add", routeIds(in.Routes), func() ([]db.RouteChange, error) {
+		return s.store.AddRoutes(in.Routes, db.Registration{
+			Peer: peerAddr(ctx),
+			TTL:  s.routeTTL(in.TtlSeconds),
+		})
+	})
+	var seq uint64
+	var msg string
+	if err == nil {
+		ids := routeIds(in.Routes)
+		for _, id := range ids {
+			s.setRegistration(id, nil)
+		}
+		seq, msg, err = s.apply(ctx, in.Wait, ids)
+	}
+	s.endAudit(change, seq)
+	if err != nil {
+		if s.legacyReplies {
+			return &pb.AddRoutesReply{
+				Result:  pb.AddRoutesReply_error,
+				Message: err.Error(),
+			}, nil
+		}
+		return nil, toStatus(err)
+	}
+	return &pb.AddRoutesReply{
+		Result:  pb.AddRoutesReply_ok,
+		Message: msg,
+	}, nil
+}
+
+func (s *Server) RemoveRoute(ctx context.Context, in *pb.RemoveRouteRequest) (*pb.RemoveRouteReply, error) {
+	var removed db.RouteChange
+	change, err := s.audited(ctx, "remove", []string{in.Id}, func() ([]db.RouteChange, error) {
+		var err error
+		removed, err = s.store.RemoveRoute(in.Id)
+		return []db.RouteChange{removed}, err
+	})
+	existed := removed.Before != nil
+	var seq uint64
+	if err == nil && existed {
+		seq, err = s.pushCaddyConf()
+	}
+	s.endAudit(change, seq)
+	if err != nil {
+		if s.legacyReplies {
+			return &pb.RemoveRouteReply{
+				Result:  pb.RemoveRouteReply_error,
+				Message: err.Error(),
+			}, nil
+		}
+		return nil, toStatus(err)
+	}
+	if existed {
+		return &pb.RemoveRouteReply{
+			Result:  pb.RemoveRouteReply_ok,
+			Message: "ok",
+			Existed: true,
+		}, nil
+	}
+	return &pb.RemoveRouteReply{
+		Result:  pb.RemoveRouteReply_ok,
+		Message: "route not found",
+	}, nil
+}
+
+func (s *Server) setRegistration(id string, reg *registration) {
+	s.registeredMutex.Lock()
+	defer s.registeredMutex.Unlock()
+	if reg == nil {
+		delete(s.registered, id)
+	} else {
+		s.registered[id] = reg
+	}
+}
+
+// unregister removes routes still kept by reg and reports whether any were
+// removed, along with the audit of their removal for endAudit.
+func (s *Server) unregister(ctx context.Context, reg *registration) (bool, *auditChange) {
+	s.registeredMutex.Lock()
+	defer s.registeredMutex.Unlock()
+	var ids []string
+	for id, r := range s.registered {
+		if r == reg {
+			ids = append(ids, id)
+		}
+	}
+	removed := false
+	change, _ := s.audited(ctx, "unregister", ids, func() ([]db.RouteChange, error) {
+		var changes []db.RouteChange
+		for _, id := range ids {
+			delete(s.registered, id)
+			if c, err := s.store.RemoveRoute(id); err == nil && c.Before != nil {
+				removed = true
+				changes = append(changes, c)
+			}
+		}
+		return changes, nil
+	})
+	return removed, change
+}
+
+func (s *Server) Register(stream pb.CaddyCfgInjector_RegisterServer) error {
+	reg := &registration{peer: peerAddr(stream.Context())}
+	defer func() {
+		removed, change := s.unregister(stream.Context(), reg)
+		if removed {
+			slog.Info("register stream ended, routes removed", "peer", reg.peer)
+			seq, _ := s.pushCaddyConf()
+			s.endAudit(change, seq)
+		}
+	}()
+
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := "ok"
+		if len(in.Routes) > 0 {
+			ids := routeIds(in.Routes)
+			var change *auditChange
+			change, err = s.audited(stream.Context(), "add", ids, func() ([]db.RouteChange, error) {
+				return s.store.AddRoutes(in.Routes, db.Registration{Peer: reg.peer})
+			})
+			var seq uint64
+			if err == nil {
+				for _, id := range ids {
+					s.setRegistration(id, reg)
+				}
+				seq, msg, err = s.apply(stream.Context(), nil, ids)
+			}
+			s.endAudit(change, seq)
+		}
+		reply := &pb.RegisterReply{
+			Result:  pb.RegisterReply_ok,
+			Message: msg,
+		}
+		if err != nil {
+			reply = &pb.RegisterReply{
+				Result:  pb.RegisterReply_error,
+				Message: err.Error(),
+			}
+		}
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+func routeInfoToProto(info db.RouteInfo) *pb.RouteInfo {
+	ri := &pb.RouteInfo{
+		Route: info.Route,
+		Peer:  info.Peer,
+	}
+	if !info.Registered.IsZero() {
+		ri.Registered = timestamppb.New(info.Registered)
+	}
+	if !info.Expires.IsZero() {
+		ri.Expires = timestamppb.New(info.Expires)
+	}
+	return ri
+}
+
+func (s *Server) ListRoutes(_ context.Context, in *pb.ListRoutesRequest) (*pb.ListRoutesReply, error) {
+	reply := &pb.ListRoutesReply{}
+	for _, info := range s.store.ListRoutes(in.IdPrefix, in.Host) {
+		reply.Routes = append(reply.Routes, routeInfoToProto(info))
+	}
+	return reply, nil
+}
+
+func (s *Server) GetRoute(_ context.Context, in *pb.GetRouteRequest) (*pb.GetRouteReply, error) {
+	info, ok := s.store.GetRoute(in.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "route %q not found", in.Id)
+	}
+	return &pb.GetRouteReply{Route: routeInfoToProto(info)}, nil
+}
+
+var eventKinds = map[events.Kind]pb.RouteEvent_Kind{
+	events.RouteAdded:    pb.RouteEvent_added,
+	events.RouteUpdated:  pb.RouteEvent_updated,
+	events.RouteRemoved:  pb.RouteEvent_removed,
+	events.RouteRejected: pb.RouteEvent_rejected,
+	events.Pushed:        pb.RouteEvent_pushed,
+	events.PushFailed:    pb.RouteEvent_pushFailed,
+}
+
+func (s *Server) WatchRoutes(_ *pb.WatchRoutesRequest, stream pb.CaddyCfgInjector_WatchRoutesServer) error {
+	// Subscribing before the snapshot so that no change is missed in between
+	ch, unsubscribe := s.store.Events().Subscribe(64)
+	defer unsubscribe()
+
+	now := timestamppb.Now()
+	for _, info := range s.store.ListRoutes("", "") {
+		err := stream.Send(&pb.RouteEvent{
+			Kind:  pb.RouteEvent_snapshot,
+			Time:  now,
+			Id:    info.Route.Id,
+			Route: routeInfoToProto(info),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&pb.RouteEvent{Kind: pb.RouteEvent_snapshotDone, Time: now}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case e, ok := <-ch:
+			if !ok {
+				return status.Errorf(codes.ResourceExhausted, "watcher fell behind, watch again")
+			}
+			re := &pb.RouteEvent{
+				Kind:    eventKinds[e.Kind],
+				Time:    timestamppb.New(e.Time),
+				Id:      e.RouteId,
+				Message: e.Message,
+			}
+			if e.Route != nil {
+				re.Route = &pb.RouteInfo{Route: e.Route}
+			}
+			if err := stream.Send(re); err != nil {
+				return err
+			}
+		}
+	}
+}
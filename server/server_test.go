@@ -0,0 +1,367 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/king8fisher/caddycfginjector/audit"
+	"github.com/king8fisher/caddycfginjector/caddy"
+	"github.com/king8fisher/caddycfginjector/db"
+	"github.com/king8fisher/caddycfginjector/internal/routetest"
+	pb "github.com/king8fisher/caddycfginjector/proto/caddycfginjector"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func newServer(t *testing.T) *Server {
+	store := db.NewStore()
+	_, err := store.SetConf([]byte(store.InitialConfSrc()))
+	assert.Nil(t, err)
+	return New(store, caddy.NewClient(store), Options{})
+}
+
+func TestServersIsolated(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ctx := context.Background()
+	s1, s2 := newServer(t), newServer(t)
+
+	reply, err := s1.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("app")})
+	a.Nil(err)
+	a.Equal(pb.AddRouteReply_ok, reply.Result)
+	_, err = s1.GetRoute(ctx, &pb.GetRouteRequest{Id: "app"})
+	a.Nil(err)
+	_, err = s2.GetRoute(ctx, &pb.GetRouteRequest{Id: "app"})
+	a.NotNil(err, "route should only be added to the store of its server")
+	a.Equal(uint64(1), s1.client.CurrentState().Desired)
+	a.Equal(uint64(0), s2.client.CurrentState().Desired, "conf should only be pushed by the client of its server")
+
+	_, err = s2.AddRoute(ctx, &pb.AddRouteRequest{Route: &pb.Route{Id: "bad"}})
+	a.NotNil(err)
+	removed, err := s1.RemoveRoute(ctx, &pb.RemoveRouteRequest{Id: "app"})
+	a.Nil(err)
+	a.True(removed.Existed)
+}
+
+// registerStream is a Register stream receiving requests from reqs until it
+// is closed.
+type registerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	reqs    chan *pb.RegisterRequest
+	replies chan *pb.RegisterReply
+}
+
+func newRegisterStream() *registerStream {
+	return &registerStream{
+		ctx:     context.Background(),
+		reqs:    make(chan *pb.RegisterRequest),
+		replies: make(chan *pb.RegisterReply, 8),
+	}
+}
+
+func (r *registerStream) Context() context.Context { return r.ctx }
+
+func (r *registerStream) Send(reply *pb.RegisterReply) error {
+	r.replies <- reply
+	return nil
+}
+
+func (r *registerStream) Recv() (*pb.RegisterRequest, error) {
+	req, ok := <-r.reqs
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ctx := context.Background()
+	s := newServer(t)
+	stream := newRegisterStream()
+	done := make(chan error)
+	go func() { done <- s.Register(stream) }()
+
+	stream.reqs <- &pb.RegisterRequest{Routes: []*pb.Route{routetest.Route("app"), routetest.Route("api")}}
+	a.Equal(pb.RegisterReply_ok, (<-stream.replies).Result)
+	_, err := s.GetRoute(ctx, &pb.GetRouteRequest{Id: "app"})
+	a.Nil(err)
+
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: &pb.Route{Id: "app"}})
+	a.NotNil(err, "route without handles should be refused")
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("api")})
+	a.Nil(err)
+	desired := s.client.CurrentState().Desired
+
+	close(stream.reqs)
+	a.Nil(<-done)
+	_, err = s.GetRoute(ctx, &pb.GetRouteRequest{Id: "app"})
+	a.NotNil(err, "route should be removed once its stream ends despite a refused AddRoute")
+	_, err = s.GetRoute(ctx, &pb.GetRouteRequest{Id: "api"})
+	a.Nil(err, "route taken over by AddRoute should be kept")
+	a.Equal(desired+1, s.client.CurrentState().Desired, "conf without removed routes should be pushed")
+}
+
+// watchStream is a WatchRoutes stream handing sent events to events.
+type watchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.RouteEvent
+}
+
+func (w *watchStream) Context() context.Context { return w.ctx }
+
+func (w *watchStream) Send(e *pb.RouteEvent) error {
+	w.events <- e
+	return nil
+}
+
+func TestWatchRoutes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ctx := context.Background()
+	s := newServer(t)
+	_, err := s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("a")})
+	a.Nil(err)
+
+	stream := &watchStream{ctx: ctx, events: make(chan *pb.RouteEvent)}
+	done := make(chan error)
+	go func() { done <- s.WatchRoutes(&pb.WatchRoutesRequest{}, stream) }()
+	e := <-stream.events
+	a.Equal(pb.RouteEvent_snapshot, e.Kind)
+	a.Equal("a", e.Id)
+	a.Equal("a", e.Route.Route.Id)
+	a.Equal(pb.RouteEvent_snapshotDone, (<-stream.events).Kind)
+
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("b")})
+	a.Nil(err)
+	e = <-stream.events
+	a.Equal(pb.RouteEvent_added, e.Kind)
+	a.Equal("b", e.Id)
+	changed := routetest.Route("b")
+	changed.Handles[0].GetReverseProxy().Upstreams[0].Dial.Port = 9000
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: changed})
+	a.Nil(err)
+	e = <-stream.events
+	a.Equal(pb.RouteEvent_updated, e.Kind)
+	a.Equal(uint32(9000), e.Route.Route.Handles[0].GetReverseProxy().Upstreams[0].Dial.Port)
+	_, err = s.RemoveRoute(ctx, &pb.RemoveRouteRequest{Id: "b"})
+	a.Nil(err)
+	e = <-stream.events
+	a.Equal(pb.RouteEvent_removed, e.Kind)
+	a.Equal("b", e.Id)
+
+	// Nothing is read from the stream meanwhile, so the watcher falls behind
+	for i := 0; i < 100; i++ {
+		_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route(fmt.Sprintf("r%d", i))})
+		a.Nil(err)
+	}
+	for {
+		select {
+		case <-stream.events:
+			continue
+		case err = <-done:
+		}
+		break
+	}
+	a.Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func TestToStatus(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name   string
+		err    error
+		code   codes.Code
+		detail proto.Message
+	}{
+		{
+			name: "field",
+			err:  fmt.Errorf("wrapped: %w", &db.FieldError{Field: "route.id", Description: "empty"}),
+			code: codes.InvalidArgument,
+			detail: &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "route.id", Description: "empty"},
+			}},
+		},
+		{
+			name: "empty conf",
+			err:  fmt.Errorf("route queued: %w", db.ErrEmptyConf),
+			code: codes.FailedPrecondition,
+			detail: &errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{
+				{Type: "CADDY_CONF", Subject: "caddy", Description: "conf has not been received from Caddy yet"},
+			}},
+		},
+		{
+			name: "route rejected",
+			err:  &db.RouteRejectedError{Id: "app", Reason: "bad handler"},
+			code: codes.InvalidArgument,
+			detail: &errdetails.ErrorInfo{
+				Reason:   "CADDY_REJECTED",
+				Domain:   "caddy",
+				Metadata: map[string]string{"id": "app", "error": "bad handler"},
+			},
+		},
+		{
+			name: "base route",
+			err:  fmt.Errorf("routes[1]: %w", &db.BaseRouteError{Id: "static"}),
+			code: codes.PermissionDenied,
+			detail: &errdetails.ErrorInfo{
+				Reason:   "BASE_ROUTE",
+				Domain:   "caddy",
+				Metadata: map[string]string{"id": "static"},
+			},
+		},
+		{
+			name: "conf rejected",
+			err:  &caddy.RejectedError{StatusCode: 400, Body: "invalid conf"},
+			code: codes.FailedPrecondition,
+			detail: &errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{
+				{Type: "CADDY_LOAD", Subject: "caddy", Description: "invalid conf"},
+			}},
+		},
+		{name: "unavailable", err: fmt.Errorf("push: %w", caddy.ErrUnavailable), code: codes.Unavailable},
+		{name: "deadline", err: context.DeadlineExceeded, code: codes.DeadlineExceeded},
+		{name: "status", err: status.Error(codes.NotFound, "missing"), code: codes.NotFound},
+		{name: "other", err: errors.New("boom"), code: codes.Internal},
+	} {
+		st := status.Convert(toStatus(tc.err))
+		assert.Equal(t, tc.code, st.Code(), tc.name)
+		assert.Equal(t, status.Convert(tc.err).Message(), st.Message(), tc.name)
+		if tc.detail == nil {
+			assert.Empty(t, st.Details(), tc.name)
+			continue
+		}
+		if assert.Equal(t, 1, len(st.Details()), tc.name) {
+			assert.True(t, proto.Equal(tc.detail, st.Details()[0].(proto.Message)), tc.name)
+		}
+	}
+	assert.Nil(t, toStatus(nil))
+}
+
+// newServerWithCaddy returns a server whose client patches a Caddy admin API
+// accepting every conf.
+func newServerWithCaddy(t *testing.T, opts Options) *Server {
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("null"))
+		}
+	}))
+	t.Cleanup(admin.Close)
+	store := db.NewStore()
+	_, err := store.SetConf([]byte(store.InitialConfSrc()))
+	assert.Nil(t, err)
+	client := caddy.NewClient(store)
+	assert.Nil(t, client.SetAdmin(admin.URL, caddy.AdminOptions{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go client.Patch(ctx, false, 0)
+	return New(store, client, opts)
+}
+
+func TestWaitTimeoutDefault(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := newServerWithCaddy(t, Options{})
+	wait := true
+	reply, err := s.AddRoute(context.Background(), &pb.AddRouteRequest{Route: routetest.Route("app"), Wait: &wait})
+	a.Nil(err)
+	a.Equal("applied", reply.GetMessage())
+}
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := audit.Open(path, 1<<20, 1)
+	a.Nil(err)
+	defer log.Close()
+	s := newServerWithCaddy(t, Options{Audit: log})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(clientIdHeader, "deployer"))
+
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: &pb.Route{Id: "app"}})
+	a.NotNil(err)
+	// Clients changing the same route concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := routetest.Route("app")
+			r.Handles[0].GetReverseProxy().Upstreams[0].Dial.Port = uint32(8000 + i)
+			_, err := s.AddRoute(ctx, &pb.AddRouteRequest{Route: r})
+			a.Nil(err)
+		}(i)
+	}
+	wg.Wait()
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("other")})
+	a.Nil(err)
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("other")})
+	a.Nil(err, "unchanged route is not audited")
+	_, err = s.RemoveRoute(ctx, &pb.RemoveRouteRequest{Id: "app"})
+	a.Nil(err)
+	_, err = s.AddRoute(ctx, &pb.AddRouteRequest{Route: routetest.Route("leased"), TtlSeconds: 60})
+	a.Nil(err)
+	s.expireRoutes(time.Now().Add(time.Hour))
+
+	var entries []audit.Entry
+	a.Eventually(func() bool {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		entries = nil
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			var e audit.Entry
+			if json.Unmarshal([]byte(line), &e) == nil {
+				entries = append(entries, e)
+			}
+		}
+		return len(entries) == 15
+	}, 5*time.Second, 10*time.Millisecond)
+	if !a.Equal(15, len(entries)) {
+		return
+	}
+	a.NotEmpty(entries[0].Error, "refused change should be audited")
+	a.False(entries[0].Pushed)
+	a.Equal("deployer", entries[0].Identity)
+	a.Equal("null", string(entries[1].Before))
+	var prev json.RawMessage
+	for i, e := range entries[1:] {
+		a.Empty(e.Error)
+		a.True(e.Pushed, e.PushError)
+		a.False(e.Time.Before(entries[i].Time), "entries should be written in order")
+		if e.RouteId != "app" {
+			continue
+		}
+		if prev != nil {
+			a.JSONEq(string(prev), string(e.Before), "entry should start from the route the previous one left")
+		}
+		prev = e.After
+	}
+	a.Equal("other", entries[11].RouteId)
+	a.Equal("remove", entries[12].Action)
+	a.Equal("null", string(entries[12].After))
+	a.Equal("expire", entries[14].Action)
+	a.Equal("leased", entries[14].RouteId)
+	a.JSONEq(string(entries[13].After), string(entries[14].Before))
+	a.Equal("null", string(entries[14].After))
+}